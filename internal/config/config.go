@@ -4,12 +4,16 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"os/user"
 	"path/filepath"
+	"regexp"
 	"strings"
 
+	"github.com/jysk-network/netbox-securecrt-inventory/pkg/evaluator"
+	"github.com/jysk-network/netbox-securecrt-inventory/pkg/securecrt"
 	"gopkg.in/yaml.v3"
 )
 
@@ -21,6 +25,10 @@ type ConfigSessionOverride struct {
 	Target    string `yaml:"target"`
 	Condition string `yaml:"condition"`
 	Value     string `yaml:"value"`
+	Else      string `yaml:"else,omitempty"`
+	Group     string `yaml:"group,omitempty"`
+	Priority  int    `yaml:"priority,omitempty"`
+	Stop      bool   `yaml:"stop,omitempty"`
 }
 
 type ConfigNameOverwrite struct {
@@ -28,33 +36,129 @@ type ConfigNameOverwrite struct {
 	Value string `yaml:"value"`
 }
 
+type ConfigVariable struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+type ConfigLookup struct {
+	Name   string            `yaml:"name"`
+	File   string            `yaml:"file,omitempty"`
+	Values map[string]string `yaml:"values,omitempty"`
+}
+
 type ConfigSessionOptions struct {
 	ConnectionProtocol string `yaml:"connection_protocol"`
 	Credential         string `yaml:"credential"`
 	Firewall           string `yaml:"firewall"`
 }
 
+type ConfigSessionVariant struct {
+	Name               string `yaml:"name"`
+	Condition          string `yaml:"condition"`
+	DeviceNameSuffix   string `yaml:"device_name_suffix"`
+	Port               int    `yaml:"port"`
+	ConnectionProtocol string `yaml:"connection_protocol"`
+	Credential         string `yaml:"credential"`
+}
+
+type ConfigConsoleProfile struct {
+	Name               string   `yaml:"name"`
+	Manufacturers      []string `yaml:"manufacturers"`
+	Platforms          []string `yaml:"platforms"`
+	Port               string   `yaml:"port"`
+	Username           string   `yaml:"username"`
+	ConnectionProtocol string   `yaml:"connection_protocol"`
+}
+
+type ConfigSerial struct {
+	Port            string `yaml:"port"`
+	DeviceName      string `yaml:"device_name"`
+	Condition       string `yaml:"condition"`
+	DefaultBaudRate int    `yaml:"default_baud_rate"`
+	DataBits        int    `yaml:"data_bits"`
+	Parity          string `yaml:"parity"`
+	StopBits        string `yaml:"stop_bits"`
+	FlowControl     string `yaml:"flow_control"`
+}
+
 type ConfigSession struct {
-	Path           string                  `yaml:"path"`
-	DeviceName     string                  `yaml:"device_name"`
-	SessionOptions ConfigSessionOptions    `yaml:"session_options"`
-	Overrides      []ConfigSessionOverride `yaml:"overrides"`
+	Path              string                  `yaml:"path"`
+	DeviceName        string                  `yaml:"device_name"`
+	SessionOptions    ConfigSessionOptions    `yaml:"session_options"`
+	Overrides         []ConfigSessionOverride `yaml:"overrides"`
+	Variants          []ConfigSessionVariant  `yaml:"variants"`
+	NoSiteName        string                  `yaml:"no_site_name"`
+	Description       string                  `yaml:"description"`
+	DescriptionBlocks []string                `yaml:"description_blocks"`
+	PathCollision     string                  `yaml:"path_collision"`
 }
 
 type Config struct {
 	configPath              string
-	LogLevel                string         `yaml:"log_level"`
-	NetboxUrl               string         `yaml:"netbox_url"`
-	NetboxToken             string         `yaml:"netbox_token"`
-	RootPath                string         `yaml:"root_path"`
-	Filters                 []ConfigFilter `yaml:"filters"`
-	Session                 ConfigSession  `yaml:"session"`
-	EnableConsoleServerSync bool           `yaml:"console_server_sync_enable"`
-	EnablePeriodicSync      bool           `yaml:"periodic_sync_enable"`
-	PeriodicSyncInterval    *int           `yaml:"periodic_sync_interval"`
+	lines                   map[string]int
+	evaluator               *evaluator.Evaluator
+	lookups                 map[string]map[string]string
+	LogLevel                string                 `yaml:"log_level"`
+	NetboxUrl               string                 `yaml:"netbox_url"`
+	NetboxToken             string                 `yaml:"netbox_token"`
+	RootPath                string                 `yaml:"root_path"`
+	Filters                 []ConfigFilter         `yaml:"filters"`
+	Variables               []ConfigVariable       `yaml:"variables"`
+	Lookups                 []ConfigLookup         `yaml:"lookups"`
+	Session                 ConfigSession          `yaml:"session"`
+	EnableConsoleServerSync bool                   `yaml:"console_server_sync_enable"`
+	ConsoleSyncMode         string                 `yaml:"console_sync_mode"`
+	ConsoleSessionNested    bool                   `yaml:"console_session_nested"`
+	ConsoleUsername         string                 `yaml:"console_username"`
+	ConsoleDeviceName       string                 `yaml:"console_device_name"`
+	ConsoleProfiles         []ConfigConsoleProfile `yaml:"console_profiles"`
+	EnableSerialSync        bool                   `yaml:"serial_sync_enable"`
+	Serial                  ConfigSerial           `yaml:"serial"`
+	EnablePeriodicSync      bool                   `yaml:"periodic_sync_enable"`
+	PeriodicSyncInterval    *int                   `yaml:"periodic_sync_interval"`
+}
+
+const (
+	CONSOLE_SYNC_MODE_SERVER = "server"
+	CONSOLE_SYNC_MODE_DEVICE = "device"
+
+	PATH_COLLISION_NETBOX_ID = "netbox_id"
+	PATH_COLLISION_SITE_SLUG = "site_slug"
+	PATH_COLLISION_COUNTER   = "counter"
+	PATH_COLLISION_ERROR     = "error"
+
+	DEFAULT_CONSOLE_DEVICE_NAME = "{device_name} ({console_port})"
+	DEFAULT_SERIAL_DEVICE_NAME  = "{device_name} (serial {console_port})"
+	DEFAULT_SESSION_DESCRIPTION = "Site: {site_name}\nType: {device_type}\nAddress: {{ replace(site_address, '\\n', ', ') }}"
+)
+
+// DescriptionBlocks are the optional NetBox fields added to the end of the session description,
+// each block is a line with a label, and is left out when the field is empty
+var DescriptionBlocks = map[string]struct {
+	Label    string
+	Variable string
+}{
+	"device_description": {"Description", "device_description"},
+	"comments":           {"Comments", "device_comments"},
+	"serial":             {"Serial", "device_serial"},
+	"asset_tag":          {"Asset Tag", "device_asset_tag"},
+	"netbox_url":         {"NetBox", "netbox_url"},
+	"last_sync":          {"Last Sync", "sync_time"},
 }
 
 func NewConfig(configPath string) (*Config, error) {
+	config, err := ReadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Save()
+	return config, nil
+}
+
+// ReadConfig loads and validates the config without saving it, for the commands that run next to the systray
+func ReadConfig(configPath string) (*Config, error) {
 	config := &Config{
 		configPath: configPath,
 	}
@@ -65,17 +169,25 @@ func NewConfig(configPath string) (*Config, error) {
 	}
 	defer file.Close()
 
+	// decode to a node first, so validation errors can refer to the line in the config
+	var root yaml.Node
 	d := yaml.NewDecoder(file)
-	if err := d.Decode(&config); err != nil {
+	if err := d.Decode(&root); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	if err := root.Decode(config); err != nil {
 		return nil, err
 	}
 
+	config.lines = make(map[string]int)
+	getNodeLines(&root, "", config.lines)
+
 	err = config.SetDefaultsAndValidate()
 	if err != nil {
 		return nil, err
 	}
 
-	config.Save()
 	return config, nil
 }
 
@@ -102,19 +214,64 @@ func (c *Config) SetDefaultsAndValidate() error {
 		c.Session.DeviceName = "{device_name}"
 	}
 
+	if c.ConsoleSyncMode == "" {
+		c.ConsoleSyncMode = CONSOLE_SYNC_MODE_SERVER
+	}
+
+	if c.ConsoleDeviceName == "" {
+		c.ConsoleDeviceName = DEFAULT_CONSOLE_DEVICE_NAME
+	}
+
+	if c.Serial.DeviceName == "" {
+		c.Serial.DeviceName = DEFAULT_SERIAL_DEVICE_NAME
+	}
+
+	if c.Serial.DefaultBaudRate == 0 {
+		c.Serial.DefaultBaudRate = 9600
+	}
+
+	if c.Serial.DataBits == 0 {
+		c.Serial.DataBits = 8
+	}
+
+	if c.Serial.Parity == "" {
+		c.Serial.Parity = "none"
+	}
+
+	if c.Serial.StopBits == "" {
+		c.Serial.StopBits = "1"
+	}
+
+	if c.Serial.FlowControl == "" {
+		c.Serial.FlowControl = "none"
+	}
+
+	if c.Session.NoSiteName == "" {
+		c.Session.NoSiteName = "No Site"
+	}
+
+	if c.Session.Description == "" {
+		c.Session.Description = DEFAULT_SESSION_DESCRIPTION
+	}
+
+	if c.Session.PathCollision == "" {
+		c.Session.PathCollision = PATH_COLLISION_NETBOX_ID
+	}
+
 	if c.Session.Path == "" {
 		c.Session.Path = "{tenant_name}/{region_name}/{site_name}/{device_role}"
 	}
 
-	// validate overrides
-	for _, override := range c.Session.Overrides {
-		if override.Target == "" {
-			return errors.New("override target can not be empty")
-		}
+	// validate all settings, templates and expressions
+	err := c.validate()
+	if err != nil {
+		return err
+	}
 
-		if override.Condition == "" {
-			return errors.New("override condition can not be empty")
-		}
+	// compile all templates and expressions once for this config
+	c.evaluator, err = evaluator.New(c.GetTemplates(), c.lookups)
+	if err != nil {
+		return err
 	}
 
 	// validate the netbox url, and allows us to strip http/https etc
@@ -127,6 +284,98 @@ func (c *Config) SetDefaultsAndValidate() error {
 	return nil
 }
 
+// GetEvaluator returns the evaluator with the templates of this config precompiled
+func (c *Config) GetEvaluator() *evaluator.Evaluator {
+	return c.evaluator
+}
+
+// consoleTraceRe matches the variables that need the full console cable trace
+var consoleTraceRe = regexp.MustCompile(`\b(console_trace|trace)\b`)
+
+// UsesConsoleTrace returns true when a template or expression uses the console cable trace,
+// the trace is fetched for each console port so it's skipped when it's not used
+func (c *Config) UsesConsoleTrace() bool {
+	for _, template := range c.GetTemplates() {
+		if consoleTraceRe.MatchString(template) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTemplates returns all templates and expressions in the config
+func (c *Config) GetTemplates() []string {
+	templates := []string{
+		c.GetDescriptionTemplate(),
+		c.Session.Path,
+		c.Session.DeviceName,
+		c.Session.SessionOptions.ConnectionProtocol,
+		c.Session.SessionOptions.Firewall,
+		c.Serial.DeviceName,
+		c.Serial.Condition,
+		c.ConsoleDeviceName,
+	}
+
+	for _, filter := range c.Filters {
+		templates = append(templates, filter.Condition)
+	}
+
+	for _, variable := range c.Variables {
+		templates = append(templates, variable.Value)
+	}
+
+	for _, override := range c.Session.Overrides {
+		templates = append(templates, override.Condition, override.Value, override.Else)
+	}
+
+	for _, variant := range c.Session.Variants {
+		templates = append(templates, variant.Condition, variant.DeviceNameSuffix, variant.ConnectionProtocol)
+	}
+
+	for _, profile := range c.GetConsoleProfiles() {
+		templates = append(templates, profile.Port, profile.Username, profile.ConnectionProtocol)
+	}
+
+	return templates
+}
+
+// GetDescriptionTemplate returns the session description template with the description blocks added,
+// each block is an expression so empty fields are left out
+func (c *Config) GetDescriptionTemplate() string {
+	template := c.Session.Description
+	for _, name := range c.Session.DescriptionBlocks {
+		block, ok := DescriptionBlocks[name]
+		if !ok {
+			continue
+		}
+		template += fmt.Sprintf("{{ %s != '' ? '\\n%s: ' + %s : '' }}", block.Variable, block.Label, block.Variable)
+	}
+	return template
+}
+
+// GetSerialSettings returns the SecureCRT serial settings for a local serial port
+func (c *Config) GetSerialSettings(port string, baudRate int) (*securecrt.SecureCRTSerialSettings, error) {
+	settings := securecrt.NewSerialSettings(port, baudRate)
+	settings.DataBits = c.Serial.DataBits
+
+	err := settings.SetParity(c.Serial.Parity)
+	if err != nil {
+		return nil, err
+	}
+
+	err = settings.SetStopBits(c.Serial.StopBits)
+	if err != nil {
+		return nil, err
+	}
+
+	err = settings.SetFlowControl(c.Serial.FlowControl)
+	if err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
 func (c *Config) Save() error {
 	data, err := yaml.Marshal(c)
 	if err != nil {
@@ -0,0 +1,90 @@
+package config
+
+// DefaultConsoleProfiles are the built-in console server profiles, a profile in the
+// config with the same name replaces the fields it sets
+var DefaultConsoleProfiles = []ConfigConsoleProfile{
+	{
+		// Opengear selects the port with the username, ex: ssh admin:port5@opengear
+		Name:               "opengear",
+		Manufacturers:      []string{"opengear"},
+		Port:               "22",
+		Username:           "{console_username}:port{console_server_port_number}",
+		ConnectionProtocol: "SSH2",
+	},
+	{
+		// Lantronix exposes each port with ssh on 3000 + port number
+		Name:               "lantronix",
+		Manufacturers:      []string{"lantronix"},
+		Port:               "{{ 3000 + console_server_port_number }}",
+		ConnectionProtocol: "SSH2",
+	},
+	{
+		// Cisco terminal servers use reverse telnet on 2000 + line number
+		Name:               "cisco",
+		Manufacturers:      []string{"cisco"},
+		Platforms:          []string{"cisco-ios", "ios"},
+		Port:               "{{ 2000 + console_server_port_number }}",
+		ConnectionProtocol: "Telnet",
+	},
+	{
+		// Avocent selects the port with the username, ex: ssh -l admin:ttyS5 avocent
+		Name:               "avocent",
+		Manufacturers:      []string{"avocent", "vertiv"},
+		Port:               "22",
+		Username:           "{console_username}:ttyS{console_server_port_number}",
+		ConnectionProtocol: "SSH2",
+	},
+}
+
+func getDefaultConsoleProfile(name string) (ConfigConsoleProfile, bool) {
+	for _, profile := range DefaultConsoleProfiles {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+
+	return ConfigConsoleProfile{}, false
+}
+
+// GetConsoleProfiles returns the configured profiles merged with the built-in profiles,
+// profiles only defined in the config are matched first
+func (c *Config) GetConsoleProfiles() []ConfigConsoleProfile {
+	var profiles []ConfigConsoleProfile
+	for _, profile := range c.ConsoleProfiles {
+		if _, isDefault := getDefaultConsoleProfile(profile.Name); !isDefault {
+			profiles = append(profiles, profile)
+		}
+	}
+
+	for _, profile := range DefaultConsoleProfiles {
+		for _, override := range c.ConsoleProfiles {
+			if override.Name != profile.Name {
+				continue
+			}
+
+			if len(override.Manufacturers) > 0 {
+				profile.Manufacturers = override.Manufacturers
+			}
+
+			if len(override.Platforms) > 0 {
+				profile.Platforms = override.Platforms
+			}
+
+			if override.Port != "" {
+				profile.Port = override.Port
+			}
+
+			if override.Username != "" {
+				profile.Username = override.Username
+			}
+
+			if override.ConnectionProtocol != "" {
+				profile.ConnectionProtocol = override.ConnectionProtocol
+			}
+		}
+
+		profiles = append(profiles, profile)
+	}
+
+	return profiles
+}
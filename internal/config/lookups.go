@@ -0,0 +1,103 @@
+package config
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lookupRefRe matches the lookup tables used by templates and expressions,
+// ex: {lookup:folders:site_group} or lookup('folders', site_group, 'Other')
+var lookupRefRe = regexp.MustCompile(`\{lookup:([^:}]+):|\blookup\(\s*['"]([^'"]+)['"]`)
+
+// getLookupRefs returns the names of the lookup tables used by a template
+func getLookupRefs(source string) []string {
+	var names []string
+	for _, match := range lookupRefRe.FindAllStringSubmatch(source, -1) {
+		names = append(names, match[1]+match[2])
+	}
+	return names
+}
+
+// loadLookups builds the lookup tables, the values from the file are loaded first so
+// the values in the config can add to or replace them
+func (c *Config) loadLookups(v *validator) map[string]map[string]string {
+	tables := make(map[string]map[string]string, len(c.Lookups))
+	for i, lookup := range c.Lookups {
+		path := fmt.Sprintf("lookups[%d]", i)
+		if lookup.Name == "" {
+			v.add(path+".name", "can not be empty")
+		} else if _, ok := tables[lookup.Name]; ok {
+			v.add(path+".name", "'%s' is used more than once", lookup.Name)
+		}
+
+		table := make(map[string]string)
+		if lookup.File != "" {
+			values, err := readLookupFile(c.getLookupPath(lookup.File))
+			if err != nil {
+				v.add(path+".file", "%s", err.Error())
+			}
+			for key, value := range values {
+				table[key] = value
+			}
+		}
+
+		for key, value := range lookup.Values {
+			table[key] = value
+		}
+		tables[lookup.Name] = table
+	}
+
+	return tables
+}
+
+// getLookupPath returns the path of a lookup file, relative paths are relative to the config file
+func (c *Config) getLookupPath(file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(filepath.Dir(c.configPath), file)
+}
+
+// readLookupFile reads a lookup table from a csv file with key and value columns,
+// or a yaml file with a map of keys and values
+func readLookupFile(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		r := csv.NewReader(f)
+		r.FieldsPerRecord = 2
+		r.TrimLeadingSpace = true
+		for {
+			record, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			values[record[0]] = record[1]
+		}
+	case ".yaml", ".yml":
+		err = yaml.NewDecoder(f).Decode(&values)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported lookup file '%s', should be .csv, .yaml or .yml", file)
+	}
+
+	return values, nil
+}
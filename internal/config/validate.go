@@ -0,0 +1,350 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jysk-network/netbox-securecrt-inventory/pkg/evaluator"
+	"github.com/jysk-network/netbox-securecrt-inventory/pkg/securecrt"
+	"gopkg.in/yaml.v3"
+)
+
+// OverrideTargets are the variables overrides can set, the other variables are inputs or set by the sync
+var OverrideTargets = []string{
+	"path", "device_name", "description", "connection_protocol", "credential", "username", "firewall",
+	"device_ip", "device_port", "serial_port", "serial_baud_rate",
+}
+
+// GetOverrideTargetKind returns the type of value an override target accepts, targets are the
+// variables in OverrideTargets, ex: device_port, or SecureCRT session keys, ex: D:Idle Timeout
+func GetOverrideTargetKind(target string) (reflect.Kind, error) {
+	if itemType, name, ok := securecrt.ParseSessionKey(target); ok {
+		if securecrt.IsSessionField(name) {
+			return reflect.Invalid, fmt.Errorf("'%s' is set by the session, use the matching variable as target instead", name)
+		}
+
+		if itemType == "D" {
+			return reflect.Int, nil
+		}
+		return reflect.String, nil
+	}
+
+	kind, ok := evaluator.FieldKind(target)
+	if !ok || !slices.Contains(OverrideTargets, target) {
+		return reflect.Invalid, fmt.Errorf("unknown target '%s', should be one of %s, or a session key, ex: D:Idle Timeout", target, strings.Join(OverrideTargets, ", "))
+	}
+	return kind, nil
+}
+
+var (
+	variableNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// variableRefRe matches references to variables, ex: {var.store} or vars.store
+	variableRefRe = regexp.MustCompile(`\bvars?\.([A-Za-z_][A-Za-z0-9_]*)`)
+)
+
+// validator collects all problems in the config, so they can be reported at once
+type validator struct {
+	lines     map[string]int
+	lookups   map[string]map[string]string
+	variables map[string]bool
+	problems  []error
+}
+
+func (v *validator) add(path string, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	if line, ok := v.lines[path]; ok {
+		v.problems = append(v.problems, fmt.Errorf("line %d: %s: %s", line, path, message))
+	} else {
+		v.problems = append(v.problems, fmt.Errorf("%s: %s", path, message))
+	}
+}
+
+// checkTemplate compiles the template, and checks the result type when kind is not reflect.Invalid
+func (v *validator) checkTemplate(path string, source string, kind reflect.Kind) {
+	if source == "" {
+		return
+	}
+
+	template, err := evaluator.Compile(source)
+	if err != nil {
+		v.add(path, "%s", strings.ReplaceAll(err.Error(), "\n", " "))
+		return
+	}
+
+	for _, name := range getLookupRefs(source) {
+		if _, ok := v.lookups[name]; !ok {
+			v.add(path, "unknown lookup table '%s'", name)
+		}
+	}
+
+	// unknown placeholders would be written as text, ex: {tenant_nam}
+	for _, name := range template.Variables() {
+		if !evaluator.IsVariable(name) {
+			v.add(path, "unknown variable '{%s}'", name)
+		}
+	}
+	for _, match := range variableRefRe.FindAllStringSubmatch(source, -1) {
+		if !v.variables[match[1]] {
+			v.add(path, "unknown variable '%s', it's not in variables", match[0])
+		}
+	}
+
+	resultKind := template.Kind()
+	if kind != reflect.Invalid && resultKind != reflect.Interface && !isConvertibleKind(resultKind, kind) {
+		v.add(path, "should return %s, but returns %s", kind, resultKind)
+	}
+}
+
+// isConvertibleKind returns true when a result of kind from can be used as kind to, numbers and bools
+// are turned into text by evaluator.AsString so they can be used for text
+func isConvertibleKind(from reflect.Kind, to reflect.Kind) bool {
+	if from == to {
+		return true
+	}
+
+	if to == reflect.String {
+		switch from {
+		case reflect.Int, reflect.Int32, reflect.Int64, reflect.Float64, reflect.Bool, reflect.Pointer:
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) checkCondition(path string, source string) {
+	template, err := evaluator.Compile(source)
+	if err == nil && !template.IsExpression() {
+		v.add(path, "should be an expression, ex: {{ site_group == 'adm' }}")
+		return
+	}
+
+	v.checkTemplate(path, source, reflect.Bool)
+}
+
+// checkOverrideValue checks the value of an override, numbers and bools are converted from strings
+// so templates can be used for them, but literal values need to be valid
+func (v *validator) checkOverrideValue(path string, source string, kind reflect.Kind) {
+	if kind != reflect.Int && kind != reflect.Bool {
+		v.checkTemplate(path, source, kind)
+		return
+	}
+
+	template, err := evaluator.Compile(source)
+	if err != nil || template.Kind() != reflect.String {
+		v.checkTemplate(path, source, kind)
+		return
+	}
+
+	v.checkTemplate(path, source, reflect.String)
+	if !strings.Contains(source, "{") {
+		if _, err := strconv.Atoi(strings.TrimSpace(source)); kind == reflect.Int && err != nil {
+			v.add(path, "should be a number")
+		}
+		if _, err := strconv.ParseBool(strings.TrimSpace(source)); kind == reflect.Bool && err != nil {
+			v.add(path, "should be true or false")
+		}
+	}
+}
+
+func (v *validator) checkProtocol(path string, protocol string) {
+	if protocol == "" || strings.Contains(protocol, "{") {
+		v.checkTemplate(path, protocol, reflect.String)
+		return
+	}
+
+	_, err := securecrt.NormalizeProtocol(protocol)
+	if err != nil {
+		v.add(path, "%s", err.Error())
+	}
+}
+
+func (c *Config) validate() error {
+	v := &validator{lines: c.lines, variables: make(map[string]bool)}
+	c.lookups = c.loadLookups(v)
+	v.lookups = c.lookups
+	for _, variable := range c.Variables {
+		v.variables[variable.Name] = true
+	}
+
+	if c.ConsoleSyncMode != CONSOLE_SYNC_MODE_SERVER && c.ConsoleSyncMode != CONSOLE_SYNC_MODE_DEVICE {
+		v.add("console_sync_mode", "should be %s or %s", CONSOLE_SYNC_MODE_SERVER, CONSOLE_SYNC_MODE_DEVICE)
+	}
+	v.checkTemplate("console_device_name", c.ConsoleDeviceName, reflect.String)
+	if c.ConsoleSessionNested && c.ConsoleDeviceName == c.Session.DeviceName {
+		v.add("console_device_name", "should not be the same as session.device_name when console_session_nested is set, the console sessions would replace the device sessions")
+	}
+
+	switch c.Session.PathCollision {
+	case PATH_COLLISION_NETBOX_ID, PATH_COLLISION_SITE_SLUG, PATH_COLLISION_COUNTER, PATH_COLLISION_ERROR:
+	default:
+		v.add("session.path_collision", "should be %s, %s, %s or %s", PATH_COLLISION_NETBOX_ID, PATH_COLLISION_SITE_SLUG, PATH_COLLISION_COUNTER, PATH_COLLISION_ERROR)
+	}
+
+	v.checkTemplate("session.description", c.Session.Description, reflect.String)
+	for i, name := range c.Session.DescriptionBlocks {
+		if _, ok := DescriptionBlocks[name]; !ok {
+			v.add(fmt.Sprintf("session.description_blocks[%d]", i), "unknown block '%s', should be one of device_description, comments, serial, asset_tag, netbox_url or last_sync", name)
+		}
+	}
+	v.checkTemplate("session.path", c.Session.Path, reflect.String)
+	v.checkTemplate("session.device_name", c.Session.DeviceName, reflect.String)
+	v.checkTemplate("session.session_options.firewall", c.Session.SessionOptions.Firewall, reflect.String)
+	v.checkProtocol("session.session_options.connection_protocol", c.Session.SessionOptions.ConnectionProtocol)
+
+	for i, filter := range c.Filters {
+		v.checkCondition(fmt.Sprintf("filters[%d].condition", i), filter.Condition)
+	}
+
+	// validate variables, they are evaluated in order so they can only refer to earlier variables
+	variableNames := make(map[string]bool)
+	for i, variable := range c.Variables {
+		path := fmt.Sprintf("variables[%d]", i)
+		if !variableNameRe.MatchString(variable.Name) {
+			v.add(path+".name", "should only contain letters, numbers and _, and not start with a number")
+		} else if variableNames[variable.Name] {
+			v.add(path+".name", "'%s' is used more than once", variable.Name)
+		}
+
+		for _, match := range variableRefRe.FindAllStringSubmatch(variable.Value, -1) {
+			// unknown variables are reported by checkTemplate
+			if v.variables[match[1]] && !variableNames[match[1]] {
+				v.add(path+".value", "refers to '%s' which is not defined before it", match[1])
+			}
+		}
+
+		if variable.Value == "" {
+			v.add(path+".value", "can not be empty")
+		}
+		v.checkTemplate(path+".value", variable.Value, reflect.Invalid)
+		variableNames[variable.Name] = true
+	}
+
+	// validate overrides
+	for i, override := range c.Session.Overrides {
+		path := fmt.Sprintf("session.overrides[%d]", i)
+		kind, err := GetOverrideTargetKind(override.Target)
+		if override.Target == "" {
+			v.add(path+".target", "can not be empty")
+		} else if err != nil {
+			v.add(path+".target", "%s", err.Error())
+		}
+
+		if override.Condition == "" {
+			v.add(path+".condition", "can not be empty")
+		} else {
+			v.checkCondition(path+".condition", override.Condition)
+		}
+
+		if override.Group != "" && override.Else != "" {
+			v.add(path+".else", "can not be used in a group, add an override with the condition {{ true }} at the end of the group instead")
+		}
+
+		// literal protocols are checked like the ones in the session options
+		check := func(path string, source string) { v.checkOverrideValue(path, source, kind) }
+		if override.Target == "connection_protocol" {
+			check = v.checkProtocol
+		}
+
+		check(path+".value", override.Value)
+		if override.Else != "" {
+			check(path+".else", override.Else)
+		}
+	}
+
+	// validate variants, each one needs a unique name and suffix so the sessions don't collide
+	variantNames := make(map[string]bool)
+	for i, variant := range c.Session.Variants {
+		path := fmt.Sprintf("session.variants[%d]", i)
+		if variant.Name == "" {
+			v.add(path+".name", "can not be empty")
+		} else if variant.Name == "default" {
+			v.add(path+".name", "'default' is reserved")
+		} else if variantNames[variant.Name] {
+			v.add(path+".name", "'%s' is used more than once", variant.Name)
+		}
+		variantNames[variant.Name] = true
+
+		if variant.Condition != "" {
+			v.checkCondition(path+".condition", variant.Condition)
+		}
+
+		if variant.DeviceNameSuffix == "" {
+			v.add(path+".device_name_suffix", "can not be empty")
+		}
+		v.checkTemplate(path+".device_name_suffix", variant.DeviceNameSuffix, reflect.String)
+
+		if variant.Port < 0 || variant.Port > 65535 {
+			v.add(path+".port", "is out of range")
+		}
+
+		v.checkProtocol(path+".connection_protocol", variant.ConnectionProtocol)
+	}
+
+	// validate console profiles, they are matched on the console server manufacturer or platform slug
+	for i, profile := range c.ConsoleProfiles {
+		path := fmt.Sprintf("console_profiles[%d]", i)
+		if profile.Name == "" {
+			v.add(path+".name", "can not be empty")
+		}
+
+		_, isDefault := getDefaultConsoleProfile(profile.Name)
+		if !isDefault && len(profile.Manufacturers) == 0 && len(profile.Platforms) == 0 {
+			v.add(path, "needs at least one manufacturer or platform")
+		}
+
+		v.checkTemplate(path+".port", profile.Port, reflect.Invalid)
+		v.checkTemplate(path+".username", profile.Username, reflect.String)
+		v.checkProtocol(path+".connection_protocol", profile.ConnectionProtocol)
+	}
+
+	// validate serial settings, by applying them to an empty serial session
+	if c.EnableSerialSync && c.Serial.Port == "" {
+		v.add("serial.port", "can not be empty when serial_sync_enable is set")
+	}
+
+	if c.Serial.DataBits < 5 || c.Serial.DataBits > 8 {
+		v.add("serial.data_bits", "should be between 5 and 8")
+	}
+
+	_, err := c.GetSerialSettings(c.Serial.Port, c.Serial.DefaultBaudRate)
+	if err != nil {
+		v.add("serial", "%s", err.Error())
+	}
+	v.checkTemplate("serial.device_name", c.Serial.DeviceName, reflect.String)
+	if c.Serial.Condition != "" {
+		v.checkCondition("serial.condition", c.Serial.Condition)
+	}
+
+	return errors.Join(v.problems...)
+}
+
+// getNodeLines saves the line of each key in the config, ex: session.overrides[0].target
+func getNodeLines(node *yaml.Node, path string, lines map[string]int) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			getNodeLines(child, path, lines)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			childPath := node.Content[i].Value
+			if path != "" {
+				childPath = path + "." + childPath
+			}
+
+			lines[childPath] = node.Content[i].Line
+			getNodeLines(node.Content[i+1], childPath, lines)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			childPath := fmt.Sprintf("%s[%d]", path, i)
+			lines[childPath] = child.Line
+			getNodeLines(child, childPath, lines)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package gui
+
+// AttachConsole does nothing, the command output already goes to the terminal outside of windows
+func AttachConsole() bool {
+	return true
+}
@@ -0,0 +1,43 @@
+//go:build windows
+
+package gui
+
+import (
+	"os"
+	"syscall"
+)
+
+const ATTACH_PARENT_PROCESS = ^uint32(0)
+
+// AttachConsole attaches to the console of the parent process, the windows build has no console
+// of its own, so the output of the commands is lost without it. Redirected output is kept as is.
+// It returns false when the output is not redirected and there is no parent console.
+func AttachConsole() bool {
+	if isValid(os.Stdout) {
+		return true
+	}
+
+	attach := syscall.NewLazyDLL("kernel32.dll").NewProc("AttachConsole")
+	r, _, _ := attach.Call(uintptr(ATTACH_PARENT_PROCESS))
+	if r == 0 {
+		return false
+	}
+
+	if out, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0); err == nil {
+		os.Stdout = out
+		if !isValid(os.Stderr) {
+			os.Stderr = out
+		}
+	}
+
+	if in, err := os.OpenFile("CONIN$", os.O_RDWR, 0); err == nil && !isValid(os.Stdin) {
+		os.Stdin = in
+	}
+
+	return true
+}
+
+func isValid(f *os.File) bool {
+	_, err := f.Stat()
+	return err == nil
+}
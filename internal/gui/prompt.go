@@ -0,0 +1,44 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Prompt asks the user for a line of text using the native dialogs of the platform,
+// ok is false when the dialog was cancelled
+func Prompt(title string, message string) (string, bool, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		script := fmt.Sprintf("Add-Type -AssemblyName Microsoft.VisualBasic; [Microsoft.VisualBasic.Interaction]::InputBox('%s', '%s')", escapePowerShell(message), escapePowerShell(title))
+		cmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+	case "darwin":
+		script := fmt.Sprintf("text returned of (display dialog %q default answer \"\" with title %q)", message, title)
+		cmd = exec.Command("osascript", "-e", script)
+	case "linux":
+		cmd = exec.Command("zenity", "--entry", "--title", title, "--text", message)
+	default:
+		return "", false, fmt.Errorf("unsupported platform")
+	}
+
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// osascript and zenity exit with an error when the dialog is cancelled
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	value := strings.TrimSpace(string(out))
+	return value, value != "", nil
+}
+
+func escapePowerShell(value string) string {
+	return strings.ReplaceAll(value, "'", "''")
+}
@@ -14,6 +14,7 @@ type SysTray struct {
 	mSyncNow        *systray.MenuItem
 	mQuit           *systray.MenuItem
 	mLogOpen        *systray.MenuItem
+	mExplain        *systray.MenuItem
 	mPeriodicSync   *systray.MenuItem
 	cfg             *config.Config
 	animationTicker *time.Ticker
@@ -42,6 +43,7 @@ func (s *SysTray) onStartup() {
 
 	s.mSyncNow = systray.AddMenuItem("Sync Inventory Now", "Start a manual sync now")
 	s.mLogOpen = systray.AddMenuItem("Open Log", "Open log file")
+	s.mExplain = systray.AddMenuItem("Explain Device", "Show how the sessions of a device are built")
 
 	systray.AddSeparator()
 	mSettings := systray.AddMenuItem("Settings", "View Settings")
@@ -68,6 +70,8 @@ func (s *SysTray) handleClicks() {
 			s.ClickedCh <- "sync"
 		case <-s.mLogOpen.ClickedCh:
 			s.ClickedCh <- "open-log"
+		case <-s.mExplain.ClickedCh:
+			s.ClickedCh <- "explain"
 		case <-s.mPeriodicSync.ClickedCh:
 			s.ClickedCh <- "periodic-sync"
 			s.cfg.EnablePeriodicSync = !s.cfg.EnablePeriodicSync
@@ -0,0 +1,13 @@
+//go:build !windows && !linux && !darwin
+
+package gui
+
+import (
+	"errors"
+	"os"
+)
+
+// MakeRaw is not supported on this platform, the input is read line by line
+func MakeRaw(f *os.File) (restore func(), err error) {
+	return nil, errors.New("raw terminal mode is not supported")
+}
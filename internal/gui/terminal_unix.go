@@ -0,0 +1,32 @@
+//go:build linux || darwin
+
+package gui
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// MakeRaw turns off line input, echo and signals of the terminal, so keys like tab are read as they are typed,
+// restore sets the terminal back to the previous mode
+func MakeRaw(f *os.File) (restore func(), err error) {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), ioctlGetTermios, uintptr(unsafe.Pointer(&termios)))
+	if errno != 0 {
+		return nil, errno
+	}
+
+	raw := termios
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO | syscall.ISIG
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), ioctlSetTermios, uintptr(unsafe.Pointer(&raw)))
+	if errno != 0 {
+		return nil, errno
+	}
+
+	return func() {
+		syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), ioctlSetTermios, uintptr(unsafe.Pointer(&termios)))
+	}, nil
+}
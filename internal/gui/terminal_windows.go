@@ -0,0 +1,35 @@
+//go:build windows
+
+package gui
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	ENABLE_PROCESSED_INPUT        = 0x0001
+	ENABLE_LINE_INPUT             = 0x0002
+	ENABLE_ECHO_INPUT             = 0x0004
+	ENABLE_VIRTUAL_TERMINAL_INPUT = 0x0200
+)
+
+// MakeRaw turns off line input and echo of the console, so keys like tab are read as they are typed,
+// restore sets the console back to the previous mode
+func MakeRaw(f *os.File) (restore func(), err error) {
+	handle := syscall.Handle(f.Fd())
+	var mode uint32
+	err = syscall.GetConsoleMode(handle, &mode)
+	if err != nil {
+		return nil, err
+	}
+
+	setConsoleMode := syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+	raw := mode&^(ENABLE_PROCESSED_INPUT|ENABLE_LINE_INPUT|ENABLE_ECHO_INPUT) | ENABLE_VIRTUAL_TERMINAL_INPUT
+	r, _, err := setConsoleMode.Call(uintptr(handle), uintptr(raw))
+	if r == 0 {
+		return nil, err
+	}
+
+	return func() { setConsoleMode.Call(uintptr(handle), uintptr(mode)) }, nil
+}
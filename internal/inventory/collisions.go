@@ -0,0 +1,165 @@
+package inventory
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jysk-network/netbox-securecrt-inventory/internal/config"
+	"github.com/jysk-network/netbox-securecrt-inventory/pkg/evaluator"
+	"github.com/jysk-network/netbox-securecrt-inventory/pkg/securecrt"
+)
+
+// pathClaims are the session paths used by a sync or explain. Sessions are added as they are built, and claimed
+// in batches sorted by NetBox object, so the session with the lowest NetBox id keeps the path of a collision no
+// matter the order NetBox returns the objects in. Paths are compared without case, as SecureCRT is mostly used
+// on case insensitive file systems.
+type pathClaims struct {
+	paths      map[string]*securecrt.SecureCRTSession
+	pending    []pathClaim
+	collisions []string
+}
+
+// pathClaim is a session waiting for its path, with the site slug used by the site_slug strategy
+type pathClaim struct {
+	session  *securecrt.SecureCRTSession
+	siteSlug string
+}
+
+func newPathClaims() *pathClaims {
+	return &pathClaims{paths: make(map[string]*securecrt.SecureCRTSession)}
+}
+
+// add queues the sessions built from the environment, until the next claimSessionPaths
+func (c *pathClaims) add(sessions []*securecrt.SecureCRTSession, env *evaluator.Environment) {
+	for _, session := range sessions {
+		c.pending = append(c.pending, pathClaim{session: session, siteSlug: env.SiteSlug})
+	}
+}
+
+// isPathUsed returns true when a claimed session has the path of the session renamed to name
+func (c *pathClaims) isPathUsed(session *securecrt.SecureCRTSession, name string) bool {
+	path := filepath.Join(filepath.Dir(session.GetFullPath()), name+".ini")
+	_, ok := c.paths[strings.ToLower(path)]
+	return ok
+}
+
+// claimSessionPaths claims the paths of the queued sessions, a session with a path claimed by another session,
+// in this batch or an earlier one, is renamed with the path_collision strategy
+func (i *InventorySync) claimSessionPaths(claims *pathClaims, trace *explainTrace) error {
+	pending := claims.pending
+	claims.pending = nil
+	slices.SortStableFunc(pending, func(a, b pathClaim) int {
+		return compareSessions(a.session, b.session)
+	})
+
+	for _, claim := range pending {
+		session := claim.session
+		existing, ok := claims.paths[strings.ToLower(session.GetFullPath())]
+		if !ok {
+			claims.paths[strings.ToLower(session.GetFullPath())] = session
+			continue
+		}
+
+		path := i.getRelativePath(session)
+		if i.cfg.Session.PathCollision == config.PATH_COLLISION_ERROR {
+			trace.add("path collision: %s is used by %s and %s", path, describeSession(existing), describeSession(session))
+			return fmt.Errorf("%w: %s is used by %s and %s", ErrorPathCollision, path, describeSession(existing), describeSession(session))
+		}
+
+		name := ""
+		switch i.cfg.Session.PathCollision {
+		case config.PATH_COLLISION_NETBOX_ID:
+			if session.NetBox != nil {
+				name = fmt.Sprintf("%s (%d)", session.DeviceName, session.NetBox.Id)
+			}
+		case config.PATH_COLLISION_SITE_SLUG:
+			name = fmt.Sprintf("%s (%s)", session.DeviceName, claim.siteSlug)
+		}
+
+		// a counter is used when the name is still taken, ex: both objects are in the same site
+		for n := 2; name == "" || claims.isPathUsed(session, name); n++ {
+			name = fmt.Sprintf("%s (%d)", session.DeviceName, n)
+		}
+
+		session.SetDeviceName(name)
+		claims.paths[strings.ToLower(session.GetFullPath())] = session
+
+		collision := fmt.Sprintf("%s is used by %s and %s, renamed to %s", path, describeSession(existing), describeSession(session), session.DeviceName)
+		claims.collisions = append(claims.collisions, collision)
+		trace.add("path collision: %s", collision)
+		slog.Warn("session path collision", slog.String("path", path), slog.String("session", describeSession(session)), slog.String("existing", describeSession(existing)), slog.String("renamed", session.DeviceName))
+	}
+
+	return nil
+}
+
+// compareSessions orders sessions by NetBox id, type and session key, sessions without a NetBox object go last
+func compareSessions(a *securecrt.SecureCRTSession, b *securecrt.SecureCRTSession) int {
+	if a.NetBox == nil || b.NetBox == nil {
+		if a.NetBox != nil {
+			return -1
+		}
+		if b.NetBox != nil {
+			return 1
+		}
+		return strings.Compare(a.GetFullPath(), b.GetFullPath())
+	}
+
+	return cmp.Or(
+		cmp.Compare(a.NetBox.Id, b.NetBox.Id),
+		strings.Compare(a.NetBox.Type, b.NetBox.Type),
+		strings.Compare(a.NetBox.Session, b.NetBox.Session),
+	)
+}
+
+func (i *InventorySync) getRelativePath(session *securecrt.SecureCRTSession) string {
+	path, err := filepath.Rel(i.scrt.GetSessionPath(), session.GetFullPath())
+	if err != nil {
+		return session.GetFullPath()
+	}
+	return filepath.ToSlash(path)
+}
+
+// describeSession returns the NetBox object of a session, ex: dcim.device 42 (default)
+func describeSession(session *securecrt.SecureCRTSession) string {
+	if session.NetBox == nil {
+		return session.DeviceName
+	}
+	return fmt.Sprintf("%s %d (%s)", session.NetBox.Type, session.NetBox.Id, session.NetBox.Session)
+}
+
+// explainSessionPaths claims the paths of the sessions of an explained object, and adds the collisions with the
+// other explained objects and with the sessions of other NetBox objects in SecureCRT, ex: from the last sync
+func (i *InventorySync) explainSessionPaths(sessions []*securecrt.SecureCRTSession, env *evaluator.Environment, claims *pathClaims, existing map[string]*securecrt.SecureCRTSession, trace *explainTrace) error {
+	trace.section("Session paths:")
+	count := len(claims.collisions)
+	claims.add(sessions, env)
+	err := i.claimSessionPaths(claims, trace)
+	if err != nil {
+		return err
+	}
+
+	found := len(claims.collisions) > count
+	for _, session := range sessions {
+		other, ok := existing[strings.ToLower(session.GetFullPath())]
+		if !ok || (session.NetBox != nil && other.NetBox.Identity() == session.NetBox.Identity()) {
+			continue
+		}
+
+		result := "keeps the path"
+		if compareSessions(session, other) > 0 {
+			result = fmt.Sprintf("is renamed with the %s path_collision strategy", i.cfg.Session.PathCollision)
+		}
+		trace.add("path collision: %s is used by %s in SecureCRT, if it still is after the sync this session %s", i.getRelativePath(session), describeSession(other), result)
+		found = true
+	}
+
+	if !found {
+		trace.add("no path collisions")
+	}
+	return nil
+}
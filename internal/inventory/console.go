@@ -0,0 +1,331 @@
+package inventory
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jysk-network/netbox-securecrt-inventory/internal/config"
+	"github.com/jysk-network/netbox-securecrt-inventory/internal/netbox"
+	"github.com/jysk-network/netbox-securecrt-inventory/pkg/evaluator"
+	"github.com/jysk-network/netbox-securecrt-inventory/pkg/securecrt"
+)
+
+var portNumberRe = regexp.MustCompile(`(\d+)\D*$`)
+
+func (i *InventorySync) getConsoleSessions(devices []netbox.DeviceWithConfigContext, consolePorts []netbox.ConsoleServerPort, data *syncData) ([]*securecrt.SecureCRTSession, error) {
+	var sessions []*securecrt.SecureCRTSession
+	withTrace := i.cfg.UsesConsoleTrace()
+	for _, port := range consolePorts {
+		if port.ConnectedEndpoints == nil || len(*port.ConnectedEndpoints) == 0 {
+			continue
+		}
+
+		oobDevice := i.findDevice(devices, port.Device.Id)
+		if oobDevice == nil {
+			return nil, fmt.Errorf("failed to find device for %s", port.Device.Name)
+		}
+
+		// the path can go through patch panels and end at multiple ports
+		endpoints, trace, err := i.getConsoleEndpoints(*port.ConnectedEndpoints, port.Id, withTrace, i.nb.GetConsoleServerPortTrace)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(endpoints) == 0 {
+			slog.Warn("console server port path is not complete", slog.String("device_name", oobDevice.Name), slog.String("port", port.Name))
+			continue
+		}
+
+		for _, endpoint := range endpoints {
+			if endpoint.Device == nil {
+				continue
+			}
+
+			endDevice, err := i.getConsoleDevice(devices, endpoint.Device.Id, data)
+			if err != nil {
+				return nil, err
+			}
+
+			env, err := i.getConsoleEnvironment(endDevice, oobDevice, consoleConnection{port.Id, port.Name, endpoint.Id, endpoint.Name}, trace, data)
+			if err != nil {
+				return nil, err
+			}
+
+			envSessions, err := i.getConsoleSessionsForEnvironment(env, endDevice.Id, data)
+			if err != nil {
+				return nil, err
+			}
+			sessions = append(sessions, envSessions...)
+		}
+	}
+
+	return sessions, nil
+}
+
+// getConsolePortSessions creates console sessions starting from the console ports of the synced
+// devices, so only the console servers the devices are connected to need to be readable
+func (i *InventorySync) getConsolePortSessions(devices []netbox.DeviceWithConfigContext, consolePorts []netbox.ConsolePort, data *syncData) ([]*securecrt.SecureCRTSession, error) {
+	var sessions []*securecrt.SecureCRTSession
+	withTrace := i.cfg.UsesConsoleTrace()
+	for _, port := range consolePorts {
+		if port.ConnectedEndpoints == nil || len(*port.ConnectedEndpoints) == 0 {
+			continue
+		}
+
+		endDevice := i.findDevice(devices, port.Device.Id)
+		if endDevice == nil {
+			continue
+		}
+
+		endpoints, trace, err := i.getConsoleEndpoints(*port.ConnectedEndpoints, port.Id, withTrace, i.nb.GetConsolePortTrace)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(endpoints) == 0 {
+			slog.Warn("console port path is not complete", slog.String("device_name", endDevice.Name), slog.String("port", port.Name))
+			continue
+		}
+
+		for _, endpoint := range endpoints {
+			if endpoint.Device == nil {
+				continue
+			}
+
+			oobDevice, err := i.getConsoleDevice(devices, endpoint.Device.Id, data)
+			if err != nil {
+				slog.Warn("failed to get console server", slog.String("device_name", endpoint.Device.Name), slog.String("error", err.Error()))
+				continue
+			}
+
+			env, err := i.getConsoleEnvironment(endDevice, oobDevice, consoleConnection{endpoint.Id, endpoint.Name, port.Id, port.Name}, trace, data)
+			if err != nil {
+				return nil, err
+			}
+
+			envSessions, err := i.getConsoleSessionsForEnvironment(env, endDevice.Id, data)
+			if err != nil {
+				return nil, err
+			}
+			sessions = append(sessions, envSessions...)
+		}
+	}
+
+	return sessions, nil
+}
+
+// getConsoleEndpoints returns the far ends of the cable path of a console port, from the connected endpoints
+// NetBox returns with the ports. The full trace is a request for each port, so it's only fetched when it's used.
+func (i *InventorySync) getConsoleEndpoints(connected []netbox.ConnectedEndpoint, id int32, withTrace bool, getTrace func(int32) (netbox.CableTrace, error)) ([]netbox.CableTermination, netbox.CableTrace, error) {
+	if !withTrace {
+		endpoints := make([]netbox.CableTermination, 0, len(connected))
+		for _, endpoint := range connected {
+			endpoints = append(endpoints, netbox.CableTermination{Id: endpoint.Id, Url: endpoint.Url, Display: endpoint.Display, Name: endpoint.Name, Device: &endpoint.Device})
+		}
+		return endpoints, nil, nil
+	}
+
+	trace, err := getTrace(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return trace.Endpoints(), trace, nil
+}
+
+// consoleConnection is the ports at both ends of a console cable, the ids identify the session as the names can change
+type consoleConnection struct {
+	serverPortId   int32
+	serverPortName string
+	portId         int32
+	portName       string
+}
+
+// getConsoleEnvironment returns the environment for a console session to endDevice through oobDevice
+func (i *InventorySync) getConsoleEnvironment(endDevice *netbox.DeviceWithConfigContext, oobDevice *netbox.DeviceWithConfigContext, ports consoleConnection, trace netbox.CableTrace, data *syncData) (*evaluator.Environment, error) {
+	ipAddress := i.getPrimaryIP(oobDevice.PrimaryIp)
+	if ipAddress == nil {
+		return nil, fmt.Errorf("primary ip is not set on %s", oobDevice.Name)
+	}
+
+	site, err := i.getSite(data.sites, endDevice.Site.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	env := i.getDeviceEnvironment(endDevice, site, data)
+	env.DeviceName = endDevice.Name
+	env.DeviceNameTemplate = i.cfg.ConsoleDeviceName
+	env.DeviceIP = *ipAddress
+	env.IsConsoleSession = true
+	env.ConsoleServerName = oobDevice.Name
+	env.ConsoleServerPort = ports.serverPortName
+	env.ConsoleServerPortId = int(ports.serverPortId)
+	env.ConsolePort = ports.portName
+	env.ConsolePortId = int(ports.portId)
+	if trace != nil {
+		env.ConsoleTrace = trace.Summary()
+		env.Trace = trace
+	}
+	env.ConsoleServerPortNumber = getPortNumber(ports.serverPortName)
+	env.ConsoleServerManufacturer = oobDevice.DeviceType.Manufacturer.Slug
+	env.ConsoleUsername = i.cfg.ConsoleUsername
+	if oobDevice.Platform != nil {
+		env.ConsoleServerPlatform = oobDevice.Platform.Slug
+	}
+
+	err = applyConsoleProfile(i.eval, i.cfg.GetConsoleProfiles(), env)
+	if err != nil {
+		return nil, err
+	}
+
+	if i.cfg.ConsoleSessionNested {
+		path, err := i.getDeviceSessionPath(endDevice, site, data)
+		if err != nil {
+			return nil, err
+		}
+		// the path is already rendered, so it's set as the value instead of the template, names with braces stay as they are
+		env.PathTemplate = ""
+		env.Path = path
+	}
+
+	return env, nil
+}
+
+// getConsoleSessionsForEnvironment builds the console sessions and saves the first one,
+// so the regular session of the device can refer to it with console_session_path
+func (i *InventorySync) getConsoleSessionsForEnvironment(env *evaluator.Environment, deviceID int32, data *syncData) ([]*securecrt.SecureCRTSession, error) {
+	sessions, err := i.getSessions(env, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sessions) > 0 {
+		data.consoleSessions[deviceID] = append(data.consoleSessions[deviceID], sessions[0])
+	}
+
+	return sessions, nil
+}
+
+// getConsoleSessionPath returns the paths of the console sessions of a device, the sessions can be
+// renamed by a path collision so it's used once their paths are claimed
+func getConsoleSessionPath(sessions []*securecrt.SecureCRTSession) string {
+	paths := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		paths = append(paths, filepath.ToSlash(filepath.Join(session.Path, session.DeviceName)))
+	}
+	return strings.Join(paths, ", ")
+}
+
+// getDeviceSessionPath returns the path of the regular session of a device, used to place
+// the console session next to it
+func (i *InventorySync) getDeviceSessionPath(device *netbox.DeviceWithConfigContext, site *netbox.Site, data *syncData) (string, error) {
+	env := i.getDeviceEnvironment(device, site, data)
+	ipAddress := i.getPrimaryIP(device.PrimaryIp)
+	if ipAddress != nil {
+		env.DeviceIP = *ipAddress
+	}
+
+	err := applyVariables(i.eval, i.cfg.Variables, env, nil)
+	if err != nil {
+		return "", err
+	}
+
+	err = applyOverrides(i.eval, i.cfg.Session.Overrides, env, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return env.Path, nil
+}
+
+// getConsoleDevice returns the device connected to a console server port, devices
+// without a primary ip are not part of the device list so they are fetched one by one
+func (i *InventorySync) getConsoleDevice(devices []netbox.DeviceWithConfigContext, id int32, data *syncData) (*netbox.DeviceWithConfigContext, error) {
+	device := i.findDevice(devices, id)
+	if device != nil {
+		return device, nil
+	}
+
+	device, ok := data.consoleDevices[id]
+	if ok {
+		return device, nil
+	}
+
+	device, err := i.nb.GetDevice(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data.consoleDevices[id] = device
+	return device, nil
+}
+
+// getPortNumber returns the last number in a port name, ex: "Port 12" returns 12
+func getPortNumber(name string) int {
+	match := portNumberRe.FindStringSubmatch(name)
+	if match == nil {
+		return 0
+	}
+
+	number, _ := strconv.Atoi(match[1])
+	return number
+}
+
+// getConsoleProfile returns the first profile matching the console server manufacturer or platform
+func getConsoleProfile(profiles []config.ConfigConsoleProfile, env *evaluator.Environment) *config.ConfigConsoleProfile {
+	for _, profile := range profiles {
+		if slices.Contains(profile.Manufacturers, env.ConsoleServerManufacturer) ||
+			(env.ConsoleServerPlatform != "" && slices.Contains(profile.Platforms, env.ConsoleServerPlatform)) {
+			return &profile
+		}
+	}
+
+	return nil
+}
+
+// applyConsoleProfile sets the port, username and protocol from the matching console profile,
+// they are used as defaults so overrides can still change them
+func applyConsoleProfile(eval *evaluator.Evaluator, profiles []config.ConfigConsoleProfile, env *evaluator.Environment) error {
+	profile := getConsoleProfile(profiles, env)
+	if profile == nil {
+		return nil
+	}
+
+	env.ConsoleProfile = profile.Name
+	if profile.Port != "" {
+		port, ok, err := eval.EvaluateInt(fmt.Sprintf("console profile %s port", profile.Name), profile.Port, env)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			env.DevicePort = port
+		}
+	}
+
+	// the username is left unset when it needs console_username and it's not set, ex: ":port5"
+	if profile.Username != "" && strings.Contains(profile.Username, "console_username") && env.ConsoleUsername == "" {
+		slog.Debug("console_username is not set, the console profile username is skipped", slog.String("profile", profile.Name), slog.String("device_name", env.DeviceName))
+	} else if profile.Username != "" {
+		username, ok, err := eval.EvaluateString(fmt.Sprintf("console profile %s username", profile.Name), profile.Username, env)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			env.Username = username
+		}
+	}
+
+	if profile.ConnectionProtocol != "" {
+		env.ConnectionProtocolTemplate = profile.ConnectionProtocol
+	}
+
+	slog.Debug("applied console profile", slog.String("device_name", env.DeviceName), slog.String("profile", profile.Name))
+	return nil
+}
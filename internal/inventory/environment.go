@@ -0,0 +1,208 @@
+package inventory
+
+import (
+	"strings"
+	"time"
+
+	"github.com/jysk-network/netbox-securecrt-inventory/internal/netbox"
+	"github.com/jysk-network/netbox-securecrt-inventory/pkg/evaluator"
+)
+
+// NetBox object types, as used by the NetBox API, ex: dcim.device
+const (
+	NETBOX_TYPE_DEVICE          = "dcim.device"
+	NETBOX_TYPE_VIRTUAL_MACHINE = "virtualization.virtualmachine"
+)
+
+func (i *InventorySync) getCommonEnvironment(sync_type string, data *syncData) *evaluator.Environment {
+	return &evaluator.Environment{
+		SessionType:                sync_type,
+		Credential:                 i.cfg.Session.SessionOptions.Credential,
+		PathTemplate:               i.cfg.Session.Path,
+		DeviceNameTemplate:         i.cfg.Session.DeviceName,
+		FirewallTemplate:           i.cfg.Session.SessionOptions.Firewall,
+		ConnectionProtocolTemplate: i.cfg.Session.SessionOptions.ConnectionProtocol,
+		DescriptionTemplate:        i.cfg.GetDescriptionTemplate(),
+		SyncTime:                   data.syncTime.Format(time.DateTime),
+		Lookup:                     i.eval.Lookup,
+	}
+}
+
+// setSiteEnvironment sets all site related fields, shared by devices, virtual machines and console sessions
+func (i *InventorySync) setSiteEnvironment(env *evaluator.Environment, site *netbox.Site, data *syncData) {
+	regionName := i.getRegionName(site)
+	regionNames := getHierarchyNames(data.regions, site.Region)
+	env.RegionNames = regionNames
+	env.RegionPath = "No Region"
+	env.RegionRoot = "No Region"
+	if len(regionNames) > 0 {
+		env.RegionPath = strings.Join(regionNames, "/")
+		env.RegionRoot = regionNames[0]
+	}
+
+	siteGroup := ""
+	if site.Group != nil {
+		siteGroup = site.Group.Slug
+		siteGroupNames := getHierarchyNames(data.siteGroups, site.Group)
+		env.SiteGroupName = siteGroupNames[len(siteGroupNames)-1]
+		env.SiteGroupPath = strings.Join(siteGroupNames, "/")
+	}
+
+	env.Site = site
+	env.SiteName = site.Display
+	env.SiteSlug = site.Slug
+	env.SiteGroup = siteGroup
+	env.SiteAddress = strings.ReplaceAll(site.PhysicalAddress, "\r\n", ", ")
+	env.RegionName = strings.ReplaceAll(regionName, "/", "")
+}
+
+func (i *InventorySync) getDeviceEnvironment(device *netbox.DeviceWithConfigContext, site *netbox.Site, data *syncData) *evaluator.Environment {
+	env := i.getCommonEnvironment("device", data)
+	env.Device = device
+	env.DevicePort = 22
+	env.DeviceName = device.Display
+	env.DeviceRole = device.Role.Name
+	env.DeviceType = device.DeviceType.Display
+	env.DeviceSerial = getStringValue(device.Serial)
+	env.DeviceAssetTag = getStringValue(device.AssetTag)
+	env.DeviceDescription = getStringValue(device.Description)
+	env.DeviceComments = getStringValue(device.Comments)
+	env.NetboxUrl = getWebUrl(device.Url)
+	env.NetboxObjectType = NETBOX_TYPE_DEVICE
+	env.NetboxObjectId = int(device.Id)
+	env.DeviceStatus = getStatusValue(device.Status)
+	env.CustomFields = device.CustomFields
+	env.Tags = getTagNames(device.Tags)
+	env.TenantName = strings.ReplaceAll(i.getTenant(*device), "/", "")
+	env.TenantSlug = i.getTenantSlug(*device)
+	i.setSiteEnvironment(env, site, data)
+	i.setTenantEnvironment(env, device.Tenant, data)
+	i.setClusterEnvironment(env, device.Cluster, data)
+
+	if device.Platform != nil {
+		env.DevicePlatform = device.Platform.Name
+		env.DevicePlatformSlug = device.Platform.Slug
+	}
+
+	env.LocationNames = getHierarchyNames(data.locations, device.Location)
+	if device.Location != nil {
+		env.LocationName = device.Location.Name
+		env.LocationPath = strings.Join(env.LocationNames, "/")
+	}
+
+	if device.Rack != nil {
+		env.RackName = device.Rack.Name
+	}
+
+	if device.VirtualChassis != nil {
+		env.VirtualChassisName = device.VirtualChassis.Name
+	}
+
+	return env
+}
+
+// setTenantEnvironment sets the tenant group fields, the tenant on devices and virtual
+// machines is a nested object without its group, so the full tenant is looked up
+func (i *InventorySync) setTenantEnvironment(env *evaluator.Environment, tenant *netbox.Tenant, data *syncData) {
+	if tenant == nil {
+		return
+	}
+
+	if fullTenant, ok := data.tenants[tenant.Id]; ok {
+		tenant = &fullTenant
+	}
+
+	env.Tenant = tenant
+	if tenant.Group != nil {
+		tenantGroupNames := getHierarchyNames(data.tenantGroups, tenant.Group)
+		env.TenantGroup = tenantGroupNames[len(tenantGroupNames)-1]
+		env.TenantGroupPath = strings.Join(tenantGroupNames, "/")
+	}
+}
+
+// setClusterEnvironment sets the cluster fields, the full cluster holds the group and type
+func (i *InventorySync) setClusterEnvironment(env *evaluator.Environment, cluster *netbox.Cluster, data *syncData) {
+	if cluster == nil {
+		return
+	}
+
+	if fullCluster, ok := data.clusters[cluster.Id]; ok {
+		cluster = &fullCluster
+	}
+
+	env.Cluster = cluster
+	env.ClusterName = cluster.Name
+	if cluster.Group != nil {
+		env.ClusterGroup = cluster.Group.Name
+	}
+
+	if cluster.Type != nil {
+		env.ClusterType = cluster.Type.Name
+	}
+}
+
+func (i *InventorySync) getVirtualMachineEnvironment(vm *netbox.VirtualMachineWithConfigContext, site *netbox.Site, data *syncData) *evaluator.Environment {
+	env := i.getCommonEnvironment("virtual_machine", data)
+	env.Device = vm
+	env.DevicePort = 22
+	env.DeviceName = vm.Display
+	env.DeviceRole = "Virtual Machine"
+	env.DeviceStatus = getStatusValue(vm.Status)
+	env.DeviceDescription = getStringValue(vm.Description)
+	env.DeviceComments = getStringValue(vm.Comments)
+	env.NetboxUrl = getWebUrl(vm.Url)
+	env.NetboxObjectType = NETBOX_TYPE_VIRTUAL_MACHINE
+	env.NetboxObjectId = int(vm.Id)
+	env.CustomFields = vm.CustomFields
+	env.Tags = getTagNames(vm.Tags)
+	env.TenantName = strings.ReplaceAll(i.getTenant(*vm), "/", "")
+	env.TenantSlug = i.getTenantSlug(*vm)
+	i.setSiteEnvironment(env, site, data)
+	i.setTenantEnvironment(env, vm.Tenant, data)
+	i.setClusterEnvironment(env, vm.Cluster, data)
+
+	if vm.Platform != nil {
+		env.DeviceType = vm.Platform.Display
+		env.DevicePlatform = vm.Platform.Name
+		env.DevicePlatformSlug = vm.Platform.Slug
+	}
+
+	return env
+}
+
+// getHierarchyNames returns the names of the object and its parents, starting at the root
+func getHierarchyNames[T any, PT netbox.Nested[T]](items map[int32]T, item PT) []string {
+	names := make([]string, 0)
+	for _, obj := range netbox.GetHierarchy(items, item) {
+		names = append(names, strings.ReplaceAll(PT(&obj).GetName(), "/", ""))
+	}
+
+	return names
+}
+
+// getWebUrl returns the NetBox web url of an object from its api url
+func getWebUrl(apiUrl string) string {
+	return strings.Replace(apiUrl, "/api/", "/", 1)
+}
+
+func getStringValue(value *string) string {
+	if value != nil {
+		return *value
+	}
+	return ""
+}
+
+func getStatusValue(status *netbox.DeviceStatus) string {
+	if status != nil {
+		return getStringValue(status.Value)
+	}
+	return ""
+}
+
+func getTagNames(tags []netbox.NestedTag) []string {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
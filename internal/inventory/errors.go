@@ -3,5 +3,7 @@ package inventory
 import "errors"
 
 var (
-	ErrorFailedToFindSite = errors.New("unable to get site")
+	ErrorFailedToFindSite   = errors.New("unable to get site")
+	ErrorFailedToFindObject = errors.New("no device or virtual machine found")
+	ErrorPathCollision      = errors.New("session path collision")
 )
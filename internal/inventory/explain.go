@@ -0,0 +1,193 @@
+package inventory
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jysk-network/netbox-securecrt-inventory/internal/netbox"
+	"github.com/jysk-network/netbox-securecrt-inventory/pkg/evaluator"
+	"github.com/jysk-network/netbox-securecrt-inventory/pkg/securecrt"
+)
+
+// explainTrace records the steps taken while building the sessions of an object,
+// a nil trace records nothing so the regular sync can pass nil
+type explainTrace struct {
+	out strings.Builder
+}
+
+func (t *explainTrace) add(format string, args ...any) {
+	if t == nil {
+		return
+	}
+
+	t.out.WriteString("  " + fmt.Sprintf(format, args...) + "\n")
+}
+
+func (t *explainTrace) section(format string, args ...any) {
+	if t == nil {
+		return
+	}
+
+	t.out.WriteString("\n" + fmt.Sprintf(format, args...) + "\n")
+}
+
+func (t *explainTrace) String() string {
+	return strings.TrimPrefix(t.out.String(), "\n")
+}
+
+// Explain fetches the devices and virtual machines matching the query, a name or a NetBox id,
+// and returns a report of how their sessions are built, without writing anything
+func (i *InventorySync) Explain(query string) (string, error) {
+	err := i.nb.TestConnection()
+	if err != nil {
+		return "", err
+	}
+
+	devices, vms, err := i.findObjects(query)
+	if err != nil {
+		return "", err
+	}
+
+	if len(devices) == 0 && len(vms) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrorFailedToFindObject, query)
+	}
+
+	data, err := i.getSyncData(func(string) {})
+	if err != nil {
+		return "", err
+	}
+
+	// the sessions in SecureCRT by path, to show the collisions with objects that are not explained
+	existing := make(map[string]*securecrt.SecureCRTSession)
+	sessions, err := i.scrt.GetSessionsByIdentity()
+	if err != nil {
+		return "", err
+	}
+	for _, session := range sessions {
+		existing[strings.ToLower(session.GetFullPath())] = session
+	}
+
+	trace := &explainTrace{}
+	for _, device := range devices {
+		trace.section("Device: %s (id %d)", device.Name, device.Id)
+		ipAddress := i.getPrimaryIP(device.PrimaryIp)
+		if ipAddress == nil {
+			trace.add("primary ip is not set, the device is not synced")
+			continue
+		}
+
+		site, err := i.getSite(data.sites, device.Site.Id)
+		if err != nil {
+			return "", err
+		}
+
+		env := i.getDeviceEnvironment(&device, site, data)
+		env.DeviceIP = *ipAddress
+		err = i.explainEnvironment(env, data, existing, trace)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for _, vm := range vms {
+		trace.section("Virtual Machine: %s (id %d)", vm.Name, vm.Id)
+		ipAddress := i.getPrimaryIP(vm.PrimaryIp)
+		if ipAddress == nil {
+			trace.add("primary ip is not set, the virtual machine is not synced")
+			continue
+		}
+
+		site, err := i.getVirtualMachineSite(vm, data)
+		if err != nil {
+			return "", err
+		}
+
+		env := i.getVirtualMachineEnvironment(&vm, site, data)
+		env.DeviceIP = *ipAddress
+		err = i.explainEnvironment(env, data, existing, trace)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return trace.String(), nil
+}
+
+// findObjects looks up devices and virtual machines by id when the query is a number, and by name otherwise
+func (i *InventorySync) findObjects(query string) ([]netbox.DeviceWithConfigContext, []netbox.VirtualMachineWithConfigContext, error) {
+	query = strings.TrimSpace(query)
+	id, err := strconv.ParseInt(query, 10, 32)
+	if err == nil {
+		var devices []netbox.DeviceWithConfigContext
+		var vms []netbox.VirtualMachineWithConfigContext
+		device, err := i.nb.GetDevice(int32(id))
+		if err == nil {
+			devices = append(devices, *device)
+		}
+
+		vm, err := i.nb.GetVirtualMachine(int32(id))
+		if err == nil {
+			vms = append(vms, *vm)
+		}
+
+		if len(devices) > 0 || len(vms) > 0 {
+			return devices, vms, nil
+		}
+	}
+
+	devices, err := i.nb.GetDevicesByName(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vms, err := i.nb.GetVirtualMachinesByName(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return devices, vms, nil
+}
+
+// explainEnvironment adds the initial environment, the evaluation steps and the resulting sessions to the trace
+func (i *InventorySync) explainEnvironment(env *evaluator.Environment, data *syncData, existing map[string]*securecrt.SecureCRTSession, trace *explainTrace) error {
+	trace.section("Initial environment:")
+	for _, field := range evaluator.Fields(env) {
+		if field.Object {
+			continue
+		}
+		trace.add("%s = %#v", field.Name, field.Value)
+	}
+
+	sessions, err := i.buildSessions(env, trace)
+	if err != nil {
+		trace.add("error: %s", err)
+		return nil
+	}
+
+	err = i.explainSessionPaths(sessions, env, data.claims, existing, trace)
+	if err != nil {
+		trace.add("error: %s", err)
+		return nil
+	}
+
+	for _, session := range sessions {
+		preview, err := session.Preview()
+		if err != nil {
+			return err
+		}
+
+		path, _ := filepath.Rel(i.scrt.GetSessionPath(), session.GetFullPath())
+		trace.section("Session: %s", path)
+		for _, line := range strings.Split(strings.TrimSpace(preview), "\n") {
+			trace.add("%s", line)
+		}
+	}
+
+	if len(sessions) == 0 {
+		trace.section("No sessions would be written")
+	}
+
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jysk-network/netbox-securecrt-inventory/internal/config"
@@ -24,9 +25,33 @@ type InventorySync struct {
 	cfg            *config.Config
 	nb             *netbox.NetBox
 	scrt           *securecrt.SecureCRT
+	eval           *evaluator.Evaluator
 	stateLogger    func(state string, message string)
 	periodicTicker *time.Ticker
 	stripRe        *regexp.Regexp
+	// syncLock stops the periodic and manual sync from running at the same time
+	syncLock sync.Mutex
+}
+
+// syncData holds the NetBox objects used to build the session environments during a sync
+type syncData struct {
+	// syncTime is the start time of the sync, the same for all sessions
+	syncTime     time.Time
+	sites        []netbox.Site
+	regions      map[int32]netbox.Region
+	locations    map[int32]netbox.Location
+	siteGroups   map[int32]netbox.SiteGroup
+	tenants      map[int32]netbox.Tenant
+	tenantGroups map[int32]netbox.TenantGroup
+	clusters     map[int32]netbox.Cluster
+
+	// devices without a primary ip, fetched when they are connected to a console server
+	consoleDevices map[int32]*netbox.DeviceWithConfigContext
+	// console sessions by the id of the device they connect to
+	consoleSessions map[int32][]*securecrt.SecureCRTSession
+	// existingSessions are the sessions of the last sync by their netbox identity, only set during a sync
+	existingSessions map[string]*securecrt.SecureCRTSession
+	claims           *pathClaims
 }
 
 func New(cfg *config.Config, nb *netbox.NetBox, scrt *securecrt.SecureCRT, stateLogger func(state string, message string)) *InventorySync {
@@ -34,6 +59,7 @@ func New(cfg *config.Config, nb *netbox.NetBox, scrt *securecrt.SecureCRT, state
 		cfg:            cfg,
 		nb:             nb,
 		scrt:           scrt,
+		eval:           cfg.GetEvaluator(),
 		stateLogger:    stateLogger,
 		periodicTicker: time.NewTicker(time.Minute * time.Duration(*cfg.PeriodicSyncInterval)),
 		stripRe:        regexp.MustCompile(`[\\/\?]`),
@@ -42,6 +68,14 @@ func New(cfg *config.Config, nb *netbox.NetBox, scrt *securecrt.SecureCRT, state
 	return &inv
 }
 
+func mapById[T any](items []T, id func(T) int32) map[int32]T {
+	result := make(map[int32]T, len(items))
+	for _, item := range items {
+		result[id(item)] = item
+	}
+	return result
+}
+
 func (i *InventorySync) getSite(sites []netbox.Site, siteID int32) (*netbox.Site, error) {
 	for x := 0; x < len(sites); x++ {
 		if sites[x].Id == siteID {
@@ -52,6 +86,39 @@ func (i *InventorySync) getSite(sites []netbox.Site, siteID int32) (*netbox.Site
 	return nil, ErrorFailedToFindSite
 }
 
+// getVirtualMachineSite returns the site of the virtual machine, falling back to the site of
+// its cluster, and a placeholder site when neither is set
+func (i *InventorySync) getVirtualMachineSite(vm netbox.VirtualMachineWithConfigContext, data *syncData) (*netbox.Site, error) {
+	if vm.Site != nil {
+		return i.getSite(data.sites, vm.Site.Id)
+	}
+
+	if vm.Cluster != nil {
+		cluster, ok := data.clusters[vm.Cluster.Id]
+		if ok && cluster.Site != nil {
+			return i.getSite(data.sites, cluster.Site.Id)
+		}
+
+		if ok && cluster.ScopeType != nil && cluster.ScopeId != nil {
+			switch *cluster.ScopeType {
+			case "dcim.site":
+				return i.getSite(data.sites, *cluster.ScopeId)
+			case "dcim.location":
+				location, ok := data.locations[*cluster.ScopeId]
+				if ok && location.Site != nil {
+					return i.getSite(data.sites, location.Site.Id)
+				}
+			}
+		}
+	}
+
+	slog.Debug("site is not set, using placeholder", slog.String("device_name", vm.Name))
+	return &netbox.Site{
+		Name:    i.cfg.Session.NoSiteName,
+		Display: i.cfg.Session.NoSiteName,
+	}, nil
+}
+
 func (i *InventorySync) getRegionName(site *netbox.Site) string {
 	if site.Region != nil {
 		return site.Region.Name
@@ -73,6 +140,20 @@ func (i *InventorySync) getTenant(device interface{}) string {
 	return "No Tenant"
 }
 
+func (i *InventorySync) getTenantSlug(device interface{}) string {
+	nd, ok := device.(netbox.DeviceWithConfigContext)
+	if ok && nd.Tenant != nil {
+		return nd.Tenant.Slug
+	}
+
+	vm, ok := device.(netbox.VirtualMachineWithConfigContext)
+	if ok && vm.Tenant != nil {
+		return vm.Tenant.Slug
+	}
+
+	return ""
+}
+
 func (i *InventorySync) findDevice(devices []netbox.DeviceWithConfigContext, id int32) *netbox.DeviceWithConfigContext {
 	for _, device := range devices {
 		if device.Id == id {
@@ -93,124 +174,145 @@ func (i *InventorySync) getPrimaryIP(primaryIP *netbox.IPAddress) *string {
 	return nil
 }
 
-func (i *InventorySync) writeSession(session *securecrt.SecureCRTSession) error {
-	err := i.scrt.WriteSession(session)
-	if err != nil {
-		return err
-	}
+// MOVE_SUFFIX is appended to the file of a session while it's moved, it doesn't end in .ini so SecureCRT ignores it
+const MOVE_SUFFIX = ".moving"
 
-	return nil
-}
+// moveSessions moves the existing sessions of the netbox objects whose path or name changed, instead of deleting
+// them and creating them again. The files are first renamed in place, so sessions that swap paths don't replace each other.
+func (i *InventorySync) moveSessions(sessions []*securecrt.SecureCRTSession, data *syncData) {
+	type sessionMove struct {
+		existing *securecrt.SecureCRTSession
+		session  *securecrt.SecureCRTSession
+		from     string
+	}
 
-func (i *InventorySync) checkFilters(env *evaluator.Environment) bool {
-	for _, filter := range i.cfg.Filters {
-		result, err := evaluator.EvaluateCondition(filter.Condition, env)
-		if err == nil && result {
+	var moves []sessionMove
+	for _, session := range sessions {
+		if session.NetBox == nil {
 			continue
 		}
 
-		slog.Debug("filtering device", slog.String("device_name", env.DeviceName), slog.String("filter", filter.Condition))
-		return false
-	}
-
-	return true
-}
-
-func (i *InventorySync) getCommonEnvironment(sync_type string) *evaluator.Environment {
-	return &evaluator.Environment{
-		SessionType:                sync_type,
-		Credential:                 i.cfg.Session.SessionOptions.Credential,
-		PathTemplate:               i.cfg.Session.Path,
-		DeviceNameTemplate:         i.cfg.Session.DeviceName,
-		FirewallTemplate:           i.cfg.Session.SessionOptions.Firewall,
-		ConnectionProtocolTemplate: i.cfg.Session.SessionOptions.ConnectionProtocol,
-	}
-}
-
-func (i *InventorySync) getConsoleSessions(devices []netbox.DeviceWithConfigContext, consolePorts []netbox.ConsoleServerPort, sites []netbox.Site) ([]*securecrt.SecureCRTSession, error) {
-	var sessions []*securecrt.SecureCRTSession
-	for _, port := range consolePorts {
-		if port.ConnectedEndpoints == nil || len(*port.ConnectedEndpoints) == 0 {
+		existing, ok := data.existingSessions[session.NetBox.Identity()]
+		if !ok || existing.GetFullPath() == session.GetFullPath() {
 			continue
 		}
 
-		oobDevice := i.findDevice(devices, port.Device.Id)
-		if oobDevice == nil {
-			return nil, fmt.Errorf("failed to find device for %s", port.Device.Name)
+		from := existing.GetFullPath()
+		err := i.scrt.MoveSession(existing, from+MOVE_SUFFIX)
+		if err != nil {
+			slog.Warn("failed to move session", slog.String("from", from), slog.String("to", session.GetFullPath()), slog.String("error", err.Error()))
+			continue
 		}
+		moves = append(moves, sessionMove{existing: existing, session: session, from: from})
+	}
 
-		endDevice := i.findDevice(devices, (*port.ConnectedEndpoints)[0].Device.Id)
-		if endDevice == nil {
-			slog.Warn("failed to find console device", slog.String("device_name", (*port.ConnectedEndpoints)[0].Device.Name))
+	for _, move := range moves {
+		err := i.scrt.MoveSession(move.existing, move.session.GetFullPath())
+		if err == nil {
+			slog.Info("moved session", slog.String("from", move.from), slog.String("to", move.session.GetFullPath()))
 			continue
 		}
+		slog.Warn("failed to move session", slog.String("from", move.from), slog.String("to", move.session.GetFullPath()), slog.String("error", err.Error()))
 
-		site, err := i.getSite(sites, endDevice.Site.Id)
+		// the session is written again, the old file is put back so it's removed with the old sessions
+		err = i.scrt.MoveSession(move.existing, move.from)
 		if err != nil {
-			return nil, err
+			err = i.scrt.DeleteSession(move.existing)
+		}
+		if err != nil {
+			slog.Warn("failed to remove moved session", slog.String("session", move.existing.GetFullPath()), slog.String("error", err.Error()))
 		}
+	}
+}
 
-		ipAddress := i.getPrimaryIP(oobDevice.PrimaryIp)
-		if ipAddress == nil {
-			return nil, fmt.Errorf("primary ip is not set on %s", oobDevice.Name)
+func (i *InventorySync) checkFilters(env *evaluator.Environment, trace *explainTrace) (bool, error) {
+	for x, filter := range i.cfg.Filters {
+		result, err := i.eval.EvaluateCondition(filter.Condition, env)
+		if err != nil {
+			trace.add("filter #%d: %s => error: %s", x+1, filter.Condition, err)
+			return false, &evaluator.ResultError{Device: env.DeviceName, Field: fmt.Sprintf("filter #%d", x+1), Template: filter.Condition, Err: err}
 		}
 
-		tenant := i.getTenant(*endDevice)
-		regionName := i.getRegionName(site)
-		siteAddress := strings.ReplaceAll(site.PhysicalAddress, "\r\n", ", ")
-		deviceType := endDevice.DeviceType.Display
-		siteGroup := ""
-		if site.Group != nil {
-			siteGroup = site.Group.Slug
+		trace.add("filter #%d: %s => %t", x+1, filter.Condition, result)
+		if result {
+			continue
 		}
 
-		virtualChassisName := ""
-		if endDevice.VirtualChassis != nil {
-			virtualChassisName = endDevice.VirtualChassis.Name
+		slog.Debug("filtering device", slog.String("device_name", env.DeviceName), slog.String("filter", filter.Condition))
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// getSessions builds the sessions for the environment, they are written once their paths are claimed
+func (i *InventorySync) getSessions(env *evaluator.Environment, data *syncData) ([]*securecrt.SecureCRTSession, error) {
+	sessions, err := i.buildSessions(env, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data.claims.add(sessions, env)
+	return sessions, nil
+}
+
+// buildSessions applies overrides and filters to the environment and each of its variants,
+// and returns a session for every one that should be synced
+func (i *InventorySync) buildSessions(env *evaluator.Environment, trace *explainTrace) ([]*securecrt.SecureCRTSession, error) {
+	trace.section("Evaluation:")
+	err := applyVariables(i.eval, i.cfg.Variables, env, trace)
+	if err != nil {
+		return nil, err
+	}
+
+	envs, err := getVariantEnvironments(i.eval, i.cfg.Session.Variants, env, trace)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []*securecrt.SecureCRTSession
+	for _, variantEnv := range envs {
+		trace.section("Variant: %s", variantEnv.Variant)
+		err = applyOverrides(i.eval, i.cfg.Session.Overrides, variantEnv, trace)
+		if err != nil {
+			return nil, err
 		}
 
-		env := i.getCommonEnvironment("device")
-		env.Device = endDevice
-		env.DevicePort = 22
-		env.DeviceName = endDevice.Name
-		env.DeviceRole = endDevice.Role.Name
-		env.DeviceType = deviceType
-		env.DeviceIP = *ipAddress
-		env.RegionName = strings.ReplaceAll(regionName, "/", "")
-		env.TenantName = strings.ReplaceAll(tenant, "/", "")
-		env.Site = site
-		env.SiteName = site.Display
-		env.SiteGroup = siteGroup
-		env.SiteAddress = siteAddress
-		env.VirtualChassisName = virtualChassisName
-		env.IsConsoleSession = true
-		env.ConsoleServerPort = port.Name
-
-		err = applyOverrides(i.cfg.Session.Overrides, env)
+		err = applyVariant(i.eval, i.cfg.Session.Variants, variantEnv, trace)
 		if err != nil {
 			return nil, err
 		}
 
 		// Check if the device should be filtered
-		shouldSaveSession := i.checkFilters(env)
-		if shouldSaveSession {
-			path := filepath.Clean(fmt.Sprintf("%s/%s/%s.ini", i.scrt.GetSessionPath(), env.Path, env.DeviceName))
-			session := getSessionWithOverrides(path, env)
-			sessions = append(sessions, session)
-			err = i.writeSession(session)
+		ok, err := i.checkFilters(variantEnv, trace)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			trace.add("filtered, the session is not written")
+			continue
+		}
+
+		path := filepath.Clean(fmt.Sprintf("%s/%s/%s.ini", i.scrt.GetSessionPath(), variantEnv.Path, variantEnv.DeviceName))
+		session := getSessionWithOverrides(path, variantEnv)
+		if variantEnv.IsSerialSession {
+			session.Serial, err = i.cfg.GetSerialSettings(variantEnv.SerialPort, variantEnv.SerialBaudRate)
 			if err != nil {
 				return nil, err
 			}
 		}
+
+		sessions = append(sessions, session)
 	}
 
 	return sessions, nil
 }
 
-func (i *InventorySync) getDeviceSessions(devices []netbox.DeviceWithConfigContext, sites []netbox.Site) ([]*securecrt.SecureCRTSession, error) {
+func (i *InventorySync) getDeviceSessions(devices []netbox.DeviceWithConfigContext, data *syncData) ([]*securecrt.SecureCRTSession, error) {
 	var sessions []*securecrt.SecureCRTSession
 	for _, device := range devices {
-		site, err := i.getSite(sites, device.Site.Id)
+		site, err := i.getSite(data.sites, device.Site.Id)
 		if err != nil {
 			return nil, err
 		}
@@ -220,64 +322,24 @@ func (i *InventorySync) getDeviceSessions(devices []netbox.DeviceWithConfigConte
 			return nil, fmt.Errorf("primary ip is not set on %s", device.Name)
 		}
 
-		tenant := i.getTenant(device)
-		regionName := i.getRegionName(site)
-		siteAddress := strings.ReplaceAll(site.PhysicalAddress, "\r\n", ", ")
-		deviceType := device.DeviceType.Display
-		siteGroup := ""
-		if site.Group != nil {
-			siteGroup = site.Group.Slug
-		}
-
-		virtualChassisName := ""
-		if device.VirtualChassis != nil {
-			virtualChassisName = device.VirtualChassis.Name
-		}
-
-		env := i.getCommonEnvironment("device")
-		env.Device = device
-		env.DevicePort = 22
-		env.DeviceName = device.Display
-		env.DeviceRole = device.Role.Name
-		env.DeviceType = deviceType
+		env := i.getDeviceEnvironment(&device, site, data)
 		env.DeviceIP = *ipAddress
-		env.RegionName = strings.ReplaceAll(regionName, "/", "")
-		env.TenantName = strings.ReplaceAll(tenant, "/", "")
-		env.Site = site
-		env.SiteName = site.Display
-		env.SiteGroup = siteGroup
-		env.SiteAddress = siteAddress
-		env.VirtualChassisName = virtualChassisName
-
-		err = applyOverrides(i.cfg.Session.Overrides, env)
+		env.ConsoleSessionPath = getConsoleSessionPath(data.consoleSessions[device.Id])
+
+		envSessions, err := i.getSessions(env, data)
 		if err != nil {
 			return nil, err
 		}
-
-		// Check if the device should be filtered
-		shouldSaveSession := i.checkFilters(env)
-		if shouldSaveSession {
-			path := filepath.Clean(fmt.Sprintf("%s/%s/%s.ini", i.scrt.GetSessionPath(), env.Path, env.DeviceName))
-			session := getSessionWithOverrides(path, env)
-			sessions = append(sessions, session)
-			err = i.writeSession(session)
-			if err != nil {
-				return nil, err
-			}
-		}
+		sessions = append(sessions, envSessions...)
 	}
 
 	return sessions, nil
 }
 
-func (i *InventorySync) getVirtualMachineSessions(devices []netbox.VirtualMachineWithConfigContext, sites []netbox.Site) ([]*securecrt.SecureCRTSession, error) {
+func (i *InventorySync) getVirtualMachineSessions(devices []netbox.VirtualMachineWithConfigContext, data *syncData) ([]*securecrt.SecureCRTSession, error) {
 	var sessions []*securecrt.SecureCRTSession
 	for _, device := range devices {
-		if device.Site == nil {
-			return nil, fmt.Errorf("site is not set on: %s", device.Name)
-		}
-
-		site, err := i.getSite(sites, device.Site.Id)
+		site, err := i.getVirtualMachineSite(device, data)
 		if err != nil {
 			return nil, err
 		}
@@ -287,121 +349,207 @@ func (i *InventorySync) getVirtualMachineSessions(devices []netbox.VirtualMachin
 			return nil, fmt.Errorf("primary ip is not set on: %s", device.Name)
 		}
 
-		tenant := i.getTenant(device)
-		regionName := i.getRegionName(site)
-		siteAddress := strings.ReplaceAll(site.PhysicalAddress, "\r\n", ", ")
-		deviceType := ""
-		if device.Platform != nil {
-			deviceType = device.Platform.Display
-		}
-
-		siteGroup := ""
-		if site.Group != nil {
-			siteGroup = site.Group.Slug
-		}
-
-		env := i.getCommonEnvironment("virtual_machine")
-		env.Device = device
-		env.DevicePort = 22
-		env.DeviceName = device.Display
-		env.DeviceRole = "Virtual Machine"
-		env.DeviceType = deviceType
+		env := i.getVirtualMachineEnvironment(&device, site, data)
 		env.DeviceIP = *ipAddress
-		env.RegionName = strings.ReplaceAll(regionName, "/", "")
-		env.TenantName = strings.ReplaceAll(tenant, "/", "")
-		env.Site = site
-		env.SiteName = site.Display
-		env.SiteGroup = siteGroup
-		env.SiteAddress = siteAddress
-
-		err = applyOverrides(i.cfg.Session.Overrides, env)
+
+		envSessions, err := i.getSessions(env, data)
 		if err != nil {
 			return nil, err
 		}
-
-		// Check if the device should be filtered
-		shouldSaveSession := i.checkFilters(env)
-		if shouldSaveSession {
-			path := filepath.Clean(fmt.Sprintf("%s/%s/%s.ini", i.scrt.GetSessionPath(), env.Path, env.DeviceName))
-			session := getSessionWithOverrides(path, env)
-			sessions = append(sessions, session)
-			err = i.writeSession(session)
-			if err != nil {
-				return nil, err
-			}
-		}
+		sessions = append(sessions, envSessions...)
 	}
 
 	return sessions, nil
 }
 
-func (i *InventorySync) runSync() error {
+// getSyncData fetches the NetBox objects shared by all sessions, progress is called with the
+// current step, explain and the playground pass a no-op so the sync status is left as is
+func (i *InventorySync) getSyncData(progress func(message string)) (*syncData, error) {
+	start := time.Now()
+	progress("Running: Getting sites")
+	sites, err := i.nb.GetSites()
+	if err != nil {
+		return nil, err
+	}
+
+	progress("Running: Getting regions, locations and groups")
+	regions, err := i.nb.GetRegions()
+	if err != nil {
+		return nil, err
+	}
+
+	locations, err := i.nb.GetLocations()
+	if err != nil {
+		return nil, err
+	}
+
+	siteGroups, err := i.nb.GetSiteGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	progress("Running: Getting tenants")
+	tenants, err := i.nb.GetTenants()
+	if err != nil {
+		return nil, err
+	}
+
+	tenantGroups, err := i.nb.GetTenantGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	progress("Running: Getting clusters")
+	clusters, err := i.nb.GetClusters()
+	if err != nil {
+		return nil, err
+	}
+
+	return &syncData{
+		syncTime:     start,
+		sites:        sites,
+		regions:      mapById(regions, func(r netbox.Region) int32 { return r.Id }),
+		locations:    mapById(locations, func(l netbox.Location) int32 { return l.Id }),
+		siteGroups:   mapById(siteGroups, func(g netbox.SiteGroup) int32 { return g.Id }),
+		tenants:      mapById(tenants, func(t netbox.Tenant) int32 { return t.Id }),
+		tenantGroups: mapById(tenantGroups, func(g netbox.TenantGroup) int32 { return g.Id }),
+		clusters:     mapById(clusters, func(c netbox.Cluster) int32 { return c.Id }),
+
+		consoleDevices:  make(map[int32]*netbox.DeviceWithConfigContext),
+		consoleSessions: make(map[int32][]*securecrt.SecureCRTSession),
+		claims:          newPathClaims(),
+	}, nil
+}
+
+// runSync writes the sessions of all objects, and returns the path collisions
+func (i *InventorySync) runSync() ([]string, error) {
 	err := i.nb.TestConnection()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	i.stateLogger(STATE_RUNNING, "Running: Getting sites")
-	sites, err := i.nb.GetSites()
+	data, err := i.getSyncData(func(message string) { i.stateLogger(STATE_RUNNING, message) })
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	data.existingSessions, err = i.scrt.GetSessionsByIdentity()
+	if err != nil {
+		return nil, err
 	}
 
 	i.stateLogger(STATE_RUNNING, "Running: Getting devices")
 	devices, err := i.nb.GetDevices()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var consolePorts []netbox.ConsoleServerPort
-	if i.cfg.EnableConsoleServerSync {
+	var consoleServerPorts []netbox.ConsoleServerPort
+	var consolePorts []netbox.ConsolePort
+	if (i.cfg.EnableConsoleServerSync && i.cfg.ConsoleSyncMode == config.CONSOLE_SYNC_MODE_DEVICE) || i.cfg.EnableSerialSync {
+		i.stateLogger(STATE_RUNNING, "Running: Getting Console Ports")
+		consolePorts, err = i.nb.GetConsolePorts()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if i.cfg.EnableConsoleServerSync && i.cfg.ConsoleSyncMode == config.CONSOLE_SYNC_MODE_SERVER {
 		i.stateLogger(STATE_RUNNING, "Running: Getting Console Server Ports")
-		consolePorts, err = i.nb.GetConsoleServerPorts()
+		consoleServerPorts, err = i.nb.GetConsoleServerPorts()
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	i.stateLogger(STATE_RUNNING, "Running: Getting Virtual Machines")
 	vms, err := i.nb.GetVirtualMachines()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	i.stateLogger(STATE_RUNNING, "Running: Writing sessions")
-	deviceSessions, err := i.getDeviceSessions(devices, sites)
+	// console sessions are built and claimed first, so device sessions can refer to their final path
+	i.stateLogger(STATE_RUNNING, "Running: Building sessions")
+	var consoleSessions []*securecrt.SecureCRTSession
+	if i.cfg.EnableConsoleServerSync && i.cfg.ConsoleSyncMode == config.CONSOLE_SYNC_MODE_DEVICE {
+		consoleSessions, err = i.getConsolePortSessions(devices, consolePorts, data)
+		if err != nil {
+			return nil, err
+		}
+	} else if i.cfg.EnableConsoleServerSync {
+		consoleSessions, err = i.getConsoleSessions(devices, consoleServerPorts, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = i.claimSessionPaths(data.claims, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	vmSessions, err := i.getVirtualMachineSessions(vms, sites)
+	deviceSessions, err := i.getDeviceSessions(devices, data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var consoleSessions []*securecrt.SecureCRTSession
-	if i.cfg.EnableConsoleServerSync {
-		consoleSessions, err = i.getConsoleSessions(devices, consolePorts, sites)
+	vmSessions, err := i.getVirtualMachineSessions(vms, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var serialSessions []*securecrt.SecureCRTSession
+	if i.cfg.EnableSerialSync {
+		serialSessions, err = i.getSerialSessions(devices, consolePorts, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = i.claimSessionPaths(data.claims, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	i.stateLogger(STATE_RUNNING, "Running: Writing sessions")
+	allSessions := append(consoleSessions, deviceSessions...)
+	allSessions = append(allSessions, vmSessions...)
+	allSessions = append(allSessions, serialSessions...)
+	i.moveSessions(allSessions, data)
+	for _, session := range allSessions {
+		err = i.scrt.WriteSession(session)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	i.stateLogger(STATE_RUNNING, "Running: Removing old sessions")
-	allSessions := append(deviceSessions, vmSessions...)
-	allSessions = append(allSessions, consoleSessions...)
 	i.scrt.RemoveSessions(allSessions)
+	i.eval.LogStats()
+	i.eval.ClearDynamic()
 
-	return nil
+	return data.claims.collisions, nil
 }
 
 func (i *InventorySync) RunSync() {
+	// a sync started while another one is running is skipped, ex: the periodic sync during a manual sync
+	if !i.syncLock.TryLock() {
+		slog.Info("sync is already running, skipping")
+		return
+	}
+	defer i.syncLock.Unlock()
+
 	lastSync := time.Now()
-	err := i.runSync()
+	collisions, err := i.runSync()
 	if err != nil {
 		i.stateLogger(STATE_ERROR, err.Error())
 	} else {
-		i.stateLogger(STATE_DONE, fmt.Sprintf("Status: Last sync @ %s", lastSync.Format("15:04")))
+		message := fmt.Sprintf("Status: Last sync @ %s", lastSync.Format("15:04"))
+		if len(collisions) > 0 {
+			message += fmt.Sprintf(", %d path collisions (see log)", len(collisions))
+			slog.Warn("session path collisions", slog.String("collisions", strings.Join(collisions, "; ")))
+		}
+		i.stateLogger(STATE_DONE, message)
 	}
 }
 
@@ -0,0 +1,119 @@
+package inventory
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+const (
+	KEY_CTRL_C    = 3
+	KEY_CTRL_D    = 4
+	KEY_BACKSPACE = 8
+	KEY_TAB       = 9
+	KEY_LF        = 10
+	KEY_CR        = 13
+	KEY_ESCAPE    = 27
+	KEY_DELETE    = 127
+)
+
+// lineEditor reads lines from a terminal in raw mode, the terminal doesn't echo the input so it's written
+// here, and tab completes the word at the end of the line with the suggestions of complete
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	complete func(line string) []string
+}
+
+// readLine returns the next line, ctrl-c clears the line and ctrl-d on an empty line returns io.EOF
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	var line []rune
+	fmt.Fprint(e.out, prompt)
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case KEY_CR, KEY_LF:
+			fmt.Fprint(e.out, "\r\n")
+			return string(line), nil
+		case KEY_CTRL_C:
+			fmt.Fprint(e.out, "^C\r\n"+prompt)
+			line = line[:0]
+		case KEY_CTRL_D:
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+		case KEY_BACKSPACE, KEY_DELETE:
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				fmt.Fprint(e.out, "\b \b")
+			}
+		case KEY_TAB:
+			line = e.completeLine(line, prompt)
+		case KEY_ESCAPE:
+			e.skipEscapeSequence()
+		default:
+			if unicode.IsPrint(r) {
+				line = append(line, r)
+				fmt.Fprint(e.out, string(r))
+			}
+		}
+	}
+}
+
+// completeLine adds the common part of the suggestions to the line, or lists them when there is nothing to add
+func (e *lineEditor) completeLine(line []rune, prompt string) []rune {
+	input := string(line)
+	suggestions := e.complete(input)
+	if len(suggestions) == 0 {
+		return line
+	}
+
+	// the suggestions start with the word being completed, ex: device.Pla for device.Platform
+	common := suggestions[0]
+	for _, suggestion := range suggestions[1:] {
+		for !strings.HasPrefix(suggestion, common) {
+			common = common[:len(common)-1]
+		}
+	}
+
+	word := ""
+	for n := min(len(common), len(input)); n > 0; n-- {
+		if strings.HasSuffix(input, common[:n]) {
+			word = common[:n]
+			break
+		}
+	}
+
+	if len(common) > len(word) {
+		added := common[len(word):]
+		fmt.Fprint(e.out, added)
+		return append(line, []rune(added)...)
+	}
+
+	if len(suggestions) > 1 {
+		fmt.Fprint(e.out, "\r\n"+strings.Join(suggestions, "  ")+"\r\n"+prompt+input)
+	}
+	return line
+}
+
+// skipEscapeSequence reads the rest of the sequence sent by keys like the arrows, ex: ESC [ A, they are not supported
+func (e *lineEditor) skipEscapeSequence() {
+	r, _, err := e.in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return
+	}
+
+	for {
+		r, _, err = e.in.ReadRune()
+		if err != nil || (r >= 0x40 && r <= 0x7e) {
+			return
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strings"
 
 	"github.com/jysk-network/netbox-securecrt-inventory/internal/config"
@@ -12,93 +13,201 @@ import (
 	"github.com/jysk-network/netbox-securecrt-inventory/pkg/securecrt"
 )
 
-func applyDefaultOverrides(env *evaluator.Environment) error {
-	protocol, err := evaluator.EvaluateResult(env.ConnectionProtocolTemplate, env)
-	if err != nil {
-		return err
+func applyDefaultOverrides(eval *evaluator.Evaluator, env *evaluator.Environment, trace *explainTrace) error {
+	// a nil result or an empty template keeps the current value
+	fields := []struct {
+		name     string
+		template string
+		value    *string
+	}{
+		{"connection_protocol", env.ConnectionProtocolTemplate, &env.ConnectionProtocol},
+		{"path", env.PathTemplate, &env.Path},
+		{"device_name", env.DeviceNameTemplate, &env.DeviceName},
+		{"firewall", env.FirewallTemplate, &env.Firewall},
+		{"description", env.DescriptionTemplate, &env.Description},
 	}
 
-	path, err := evaluator.EvaluateResult(env.PathTemplate, env)
-	if err != nil {
-		return err
-	}
+	for _, field := range fields {
+		if field.template == "" {
+			trace.add("%s: %q", field.name, *field.value)
+			continue
+		}
 
-	device_name, err := evaluator.EvaluateResult(env.DeviceNameTemplate, env)
-	if err != nil {
-		return err
-	}
+		value, ok, err := eval.EvaluateString(field.name, field.template, env)
+		if err != nil {
+			return err
+		}
 
-	firewall, err := evaluator.EvaluateResult(env.FirewallTemplate, env)
-	if err != nil {
-		return err
+		if ok {
+			*field.value = value
+		}
+		trace.add("%s: %q => %q", field.name, field.template, *field.value)
 	}
 
-	//TODO: should we check for string type here? yeah..
-	env.ConnectionProtocol = protocol.(string)
-	env.Path = path.(string)
-	env.DeviceName = device_name.(string)
-	env.Firewall = firewall.(string)
-
-	address := strings.ReplaceAll(env.SiteAddress, "\n", ", ")
-	env.Description = fmt.Sprintf("Site: %s", env.SiteName) + fmt.Sprintf("\nType: %s", env.DeviceType) + fmt.Sprintf("\nAdresse: %s", address)
 	return nil
 }
 
-func applyOverrides(overrides []config.ConfigSessionOverride, env *evaluator.Environment) error {
+func applyOverrides(eval *evaluator.Evaluator, overrides []config.ConfigSessionOverride, env *evaluator.Environment, trace *explainTrace) error {
 	if slog.Default().Enabled(context.TODO(), slog.LevelDebug) {
 		data, _ := json.Marshal(env)
 		slog.Debug("Starting Override Evaluation", slog.String("device", env.DeviceName), slog.String("env", string(data)))
 	}
 
-	err := applyDefaultOverrides(env)
+	err := applyDefaultOverrides(eval, env, trace)
 	if err != nil {
 		return err
 	}
 
-	for _, override := range overrides {
-		shouldOverride, err := evaluator.EvaluateCondition(override.Condition, env)
-		if err != nil || !shouldOverride {
+	winners := make(map[string]string)
+	stopped, stopPriority := false, 0
+	for _, unit := range getOverrideUnits(overrides) {
+		// stop only skips the overrides up to its own priority, the units are sorted so higher priorities still run
+		if stopped && unit.priority <= stopPriority {
 			continue
 		}
 
-		val, err := evaluator.EvaluateResult(override.Value, env)
+		for _, x := range unit.overrides {
+			override := overrides[x]
+			name := fmt.Sprintf("override #%d", x+1)
+			if unit.group != "" {
+				name += fmt.Sprintf(" in group %s", unit.group)
+			}
+
+			shouldOverride, err := eval.EvaluateCondition(override.Condition, env)
+			if err != nil {
+				trace.add("%s (%s): %s => error: %s", name, override.Target, override.Condition, err)
+				return &evaluator.ResultError{Device: env.DeviceName, Field: fmt.Sprintf("%s (%s condition)", override.Target, name), Template: override.Condition, Err: err}
+			}
+
+			value := override.Value
+			if !shouldOverride {
+				if override.Else == "" {
+					trace.add("%s (%s): %s => false", name, override.Target, override.Condition)
+					continue
+				}
+				value = override.Else
+			}
+
+			val, err := eval.EvaluateResult(value, env)
+			if err == nil {
+				trace.add("%s (%s): %s => %t, %q => %#v", name, override.Target, override.Condition, shouldOverride, value, val)
+				err = setOverrideTarget(env, override.Target, val)
+			}
+			if err != nil {
+				return &evaluator.ResultError{Device: env.DeviceName, Field: fmt.Sprintf("%s (%s)", override.Target, name), Template: value, Err: err}
+			}
+			// a nil result keeps the current value, so the override didn't set the target
+			if val != nil {
+				winners[override.Target] = name
+			}
+			if override.Stop && shouldOverride {
+				trace.add("%s has stop set, the remaining overrides up to priority %d are skipped", name, unit.priority)
+				stopped, stopPriority = true, unit.priority
+				break
+			}
+
+			// only the first match of a group is applied
+			if unit.group != "" {
+				break
+			}
+		}
+	}
+
+	if len(winners) > 0 {
+		targets := make([]string, 0, len(winners))
+		for target, name := range winners {
+			targets = append(targets, fmt.Sprintf("%s: %s", target, name))
+		}
+		sort.Strings(targets)
+		trace.add("winning overrides: %s", strings.Join(targets, ", "))
+		slog.Debug("Override Results", slog.String("device", env.DeviceName), slog.String("winners", strings.Join(targets, ", ")))
+	}
+
+	return nil
+}
+
+// setOverrideTarget sets a field of the environment, or a SecureCRT session key, ex: D:Idle Timeout.
+// Strings are converted to numbers for number fields, and nil keeps the current value
+func setOverrideTarget(env *evaluator.Environment, target string, val any) error {
+	if val == nil {
+		return nil
+	}
+
+	if itemType, name, ok := securecrt.ParseSessionKey(target); ok {
+		value, _, err := evaluator.AsString(val)
+		if err != nil {
+			return err
+		}
+
+		key, err := securecrt.NewSessionKey(itemType, name, value)
 		if err != nil {
 			return err
 		}
 
-		sVal, ok := val.(string)
-		if val != nil && ok {
-			switch override.Target {
-			case "path":
-				env.Path = sVal
-			case "device_name":
-				env.DeviceName = sVal
-			case "description":
-				env.Description = sVal
-			case "connection_protocol":
-				env.ConnectionProtocol = sVal
-			case "credential":
-				env.Credential = sVal
-			case "firewall":
-				if sVal == "" || sVal == "None" {
-					env.Firewall = "None"
-				} else {
-					env.Firewall = "Session:" + sVal
-				}
+		if env.SessionKeys == nil {
+			env.SessionKeys = make(map[string]string)
+		}
+		env.SessionKeys[key.Type+":"+key.Name] = key.Value
+		return nil
+	}
 
-			}
+	if target == "firewall" {
+		sVal, _, err := evaluator.AsString(val)
+		if err != nil {
+			return err
 		}
 
-		iVal, ok := val.(int)
-		if val != nil && ok {
-			switch override.Target {
-			case "device_port":
-				env.DevicePort = iVal
-			}
+		if sVal == "" || sVal == "None" {
+			env.Firewall = "None"
+		} else {
+			env.Firewall = "Session:" + sVal
+		}
+		return nil
+	}
+
+	return evaluator.SetField(env, target, val)
+}
+
+// overrideUnit is a single override, or a group of overrides where only the first match is applied
+type overrideUnit struct {
+	group     string
+	priority  int
+	overrides []int
+}
+
+// getOverrideUnits returns the overrides in evaluation order, from the lowest to the highest priority so
+// the highest priority match wins, and the config order for the same priority. A group is evaluated at the
+// position of its first override with the highest priority of its overrides, and its overrides are tried
+// from the highest priority.
+func getOverrideUnits(overrides []config.ConfigSessionOverride) []*overrideUnit {
+	var units []*overrideUnit
+	groups := make(map[string]*overrideUnit)
+	for x, override := range overrides {
+		if override.Group == "" {
+			units = append(units, &overrideUnit{priority: override.Priority, overrides: []int{x}})
+			continue
+		}
+
+		unit, ok := groups[override.Group]
+		if !ok {
+			unit = &overrideUnit{group: override.Group, priority: override.Priority}
+			groups[override.Group] = unit
+			units = append(units, unit)
 		}
+		unit.priority = max(unit.priority, override.Priority)
+		unit.overrides = append(unit.overrides, x)
+	}
+
+	for _, unit := range groups {
+		sort.SliceStable(unit.overrides, func(a, b int) bool {
+			return overrides[unit.overrides[a]].Priority > overrides[unit.overrides[b]].Priority
+		})
 	}
 
-	return err
+	sort.SliceStable(units, func(a, b int) bool {
+		return units[a].priority < units[b].priority
+	})
+	return units
 }
 
 func getSessionWithOverrides(fullPath string, env *evaluator.Environment) *securecrt.SecureCRTSession {
@@ -108,9 +217,37 @@ func getSessionWithOverrides(fullPath string, env *evaluator.Environment) *secur
 	session.Path = env.Path
 	session.DeviceName = env.DeviceName
 	session.CredentialName = env.Credential
+	if env.Username != "" {
+		session.Username = &env.Username
+	}
 	session.Description = env.Description
 	session.Protocol = env.ConnectionProtocol
 	session.Firewall = env.Firewall
+	if env.NetboxObjectType != "" {
+		session.NetBox = &securecrt.SecureCRTNetBoxObject{Type: env.NetboxObjectType, Id: env.NetboxObjectId, Session: getSessionKey(env), Url: env.NetboxUrl}
+	}
+
+	keys := make([]string, 0, len(env.SessionKeys))
+	for key := range env.SessionKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		itemType, name, _ := securecrt.ParseSessionKey(key)
+		session.Keys = append(session.Keys, securecrt.SecureCRTSessionKey{Type: itemType, Name: name, Value: env.SessionKeys[key]})
+	}
 
 	return session
 }
+
+// getSessionKey returns the key of a session within its NetBox object, as an object can have a session for
+// each variant, console server port and console port. The port ids are used as the names can change.
+func getSessionKey(env *evaluator.Environment) string {
+	switch {
+	case env.IsConsoleSession:
+		return fmt.Sprintf("console:%d:%d:%s", env.ConsoleServerPortId, env.ConsolePortId, env.Variant)
+	case env.IsSerialSession:
+		return fmt.Sprintf("serial:%d:%s", env.ConsolePortId, env.Variant)
+	}
+	return env.Variant
+}
@@ -0,0 +1,267 @@
+package inventory
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jysk-network/netbox-securecrt-inventory/internal/netbox"
+	"github.com/jysk-network/netbox-securecrt-inventory/pkg/evaluator"
+)
+
+const playgroundHelp = `Enter a template, ex: {site_name}/{device_role}, or an expression, ex: upper(device_name)
+Press tab to complete the field at the end of the line, or end a line with ? to list the fields starting with it, ex: device.Pla?
+Commands:
+  :fields [prefix]  show the fields and their values
+  :load <query>     load another device or virtual machine, by name, NetBox ID or saved json file
+  :save <file>      save the loaded environment to a json file, to use it without NetBox
+  :help             show this help
+  :quit             exit the playground
+`
+
+// playgroundSnapshot is a saved environment, the NetBox objects are stored with their
+// type so expressions work the same way as with live data
+type playgroundSnapshot struct {
+	Environment    *evaluator.Environment                  `json:"environment"`
+	Device         *netbox.DeviceWithConfigContext         `json:"device,omitempty"`
+	VirtualMachine *netbox.VirtualMachineWithConfigContext `json:"virtual_machine,omitempty"`
+	Site           *netbox.Site                            `json:"site,omitempty"`
+	Tenant         *netbox.Tenant                          `json:"tenant,omitempty"`
+	Cluster        *netbox.Cluster                         `json:"cluster,omitempty"`
+}
+
+// Playground reads templates and expressions from in, and writes the results of evaluating
+// them against the environment of the device or virtual machine matching the query.
+// When terminal is set, in is a terminal in raw mode and the fields are completed with tab.
+func (i *InventorySync) Playground(query string, in io.Reader, out io.Writer, terminal bool) error {
+	env, err := i.loadPlaygroundEnvironment(query)
+	if err != nil {
+		return err
+	}
+
+	readLine := getLineReader(in, out)
+	if terminal {
+		editor := &lineEditor{in: bufio.NewReader(in), out: out, complete: func(line string) []string {
+			return evaluator.Suggest(env, line)
+		}}
+		readLine = editor.readLine
+	}
+
+	fmt.Fprintf(out, "Loaded %s %s, type :help for help\n", env.SessionType, env.DeviceName)
+	for {
+		line, err := readLine("> ")
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimRight(line, " \r")
+		command, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch {
+		case line == "":
+			continue
+		case command == ":quit" || command == ":q" || command == "exit":
+			return nil
+		case command == ":help":
+			fmt.Fprint(out, playgroundHelp)
+		case command == ":fields":
+			for _, field := range evaluator.Fields(env) {
+				if !strings.HasPrefix(field.Name, arg) {
+					continue
+				}
+				if field.Object {
+					fmt.Fprintf(out, "%s (object)\n", field.Name)
+					continue
+				}
+				fmt.Fprintf(out, "%s = %#v\n", field.Name, field.Value)
+			}
+		case command == ":load":
+			newEnv, err := i.loadPlaygroundEnvironment(arg)
+			if err != nil {
+				fmt.Fprintf(out, "error: %s\n", err)
+				continue
+			}
+			env = newEnv
+			fmt.Fprintf(out, "Loaded %s %s\n", env.SessionType, env.DeviceName)
+		case command == ":save":
+			err := savePlaygroundEnvironment(arg, env)
+			if err != nil {
+				fmt.Fprintf(out, "error: %s\n", err)
+			}
+		case strings.HasSuffix(line, "?"):
+			suggestions := evaluator.Suggest(env, strings.TrimSuffix(line, "?"))
+			if len(suggestions) == 0 {
+				fmt.Fprintln(out, "no matching fields")
+			}
+			for _, suggestion := range suggestions {
+				fmt.Fprintln(out, suggestion)
+			}
+		default:
+			result, err := evaluatePlayground(line, env)
+			if err != nil {
+				fmt.Fprintf(out, "error: %s\n", err)
+				continue
+			}
+			fmt.Fprintf(out, "=> %#v\n", result)
+		}
+	}
+}
+
+// getLineReader returns a function reading the lines of in, for input that is not a terminal, ex: when it's redirected
+func getLineReader(in io.Reader, out io.Writer) func(prompt string) (string, error) {
+	scanner := bufio.NewScanner(in)
+	return func(prompt string) (string, error) {
+		fmt.Fprint(out, prompt)
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			if scanner.Err() != nil {
+				return "", scanner.Err()
+			}
+			return "", io.EOF
+		}
+		return scanner.Text(), nil
+	}
+}
+
+// evaluatePlayground evaluates the input as a template when it contains braces, and as an expression otherwise
+func evaluatePlayground(input string, env *evaluator.Environment) (any, error) {
+	if !strings.Contains(input, "{") {
+		input = "{{ " + input + " }}"
+	}
+
+	template, err := evaluator.Compile(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return template.Evaluate(env)
+}
+
+// loadPlaygroundEnvironment returns the environment of the first device or virtual machine matching
+// the query with the variables and overrides applied, or the environment saved in a json file
+func (i *InventorySync) loadPlaygroundEnvironment(query string) (*evaluator.Environment, error) {
+	query = strings.TrimSpace(query)
+	if strings.HasSuffix(query, ".json") {
+		env, err := loadPlaygroundSnapshot(query)
+		if err != nil {
+			return nil, err
+		}
+
+		env.Lookup = i.eval.Lookup
+		return env, nil
+	}
+
+	err := i.nb.TestConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	devices, vms, err := i.findObjects(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(devices) == 0 && len(vms) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrorFailedToFindObject, query)
+	}
+
+	data, err := i.getSyncData(func(string) {})
+	if err != nil {
+		return nil, err
+	}
+
+	var env *evaluator.Environment
+	if len(devices) > 0 {
+		site, err := i.getSite(data.sites, devices[0].Site.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		env = i.getDeviceEnvironment(&devices[0], site, data)
+		if ipAddress := i.getPrimaryIP(devices[0].PrimaryIp); ipAddress != nil {
+			env.DeviceIP = *ipAddress
+		}
+	} else {
+		site, err := i.getVirtualMachineSite(vms[0], data)
+		if err != nil {
+			return nil, err
+		}
+
+		env = i.getVirtualMachineEnvironment(&vms[0], site, data)
+		if ipAddress := i.getPrimaryIP(vms[0].PrimaryIp); ipAddress != nil {
+			env.DeviceIP = *ipAddress
+		}
+	}
+
+	env.Variant = DEFAULT_VARIANT
+	err = applyVariables(i.eval, i.cfg.Variables, env, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = applyOverrides(i.eval, i.cfg.Session.Overrides, env, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+func savePlaygroundEnvironment(file string, env *evaluator.Environment) error {
+	snapshot := playgroundSnapshot{Environment: env}
+	snapshot.Device, _ = env.Device.(*netbox.DeviceWithConfigContext)
+	snapshot.VirtualMachine, _ = env.Device.(*netbox.VirtualMachineWithConfigContext)
+	snapshot.Site, _ = env.Site.(*netbox.Site)
+	snapshot.Tenant, _ = env.Tenant.(*netbox.Tenant)
+	snapshot.Cluster, _ = env.Cluster.(*netbox.Cluster)
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(strings.TrimSpace(file), data, 0644)
+}
+
+func loadPlaygroundSnapshot(file string) (*evaluator.Environment, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot playgroundSnapshot
+	err = json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	if snapshot.Environment == nil {
+		return nil, fmt.Errorf("no environment in %s", file)
+	}
+
+	env := snapshot.Environment
+	env.Device, env.Site, env.Tenant, env.Cluster, env.Trace = nil, nil, nil, nil, nil
+	if snapshot.Device != nil {
+		env.Device = snapshot.Device
+	}
+	if snapshot.VirtualMachine != nil {
+		env.Device = snapshot.VirtualMachine
+	}
+	if snapshot.Site != nil {
+		env.Site = snapshot.Site
+	}
+	if snapshot.Tenant != nil {
+		env.Tenant = snapshot.Tenant
+	}
+	if snapshot.Cluster != nil {
+		env.Cluster = snapshot.Cluster
+	}
+
+	return env, nil
+}
@@ -0,0 +1,62 @@
+package inventory
+
+import (
+	"fmt"
+
+	"github.com/jysk-network/netbox-securecrt-inventory/internal/netbox"
+	"github.com/jysk-network/netbox-securecrt-inventory/pkg/securecrt"
+)
+
+// getSerialSessions creates a local serial session for each console port of the synced devices matching
+// the serial condition, for devices connected directly to this computer with a console cable
+func (i *InventorySync) getSerialSessions(devices []netbox.DeviceWithConfigContext, consolePorts []netbox.ConsolePort, data *syncData) ([]*securecrt.SecureCRTSession, error) {
+	var sessions []*securecrt.SecureCRTSession
+	for _, port := range consolePorts {
+		device := i.findDevice(devices, port.Device.Id)
+		if device == nil {
+			continue
+		}
+
+		site, err := i.getSite(data.sites, device.Site.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		baudRate := i.cfg.Serial.DefaultBaudRate
+		if port.Speed != nil && port.Speed.Value != nil {
+			baudRate = int(*port.Speed.Value)
+		}
+
+		env := i.getDeviceEnvironment(device, site, data)
+		env.DeviceNameTemplate = i.cfg.Serial.DeviceName
+		env.ConnectionProtocolTemplate = "Serial"
+		env.FirewallTemplate = "None"
+		env.DevicePort = 0
+		env.IsSerialSession = true
+		env.ConsolePort = port.Name
+		env.ConsolePortId = int(port.Id)
+		env.SerialPort = i.cfg.Serial.Port
+		env.SerialBaudRate = baudRate
+		env.ConsolePortConnected = port.ConnectedEndpoints != nil && len(*port.ConnectedEndpoints) > 0
+
+		// the condition limits the serial sessions to the ports used with a local cable, ex: lab devices
+		if i.cfg.Serial.Condition != "" {
+			ok, err := i.eval.EvaluateCondition(i.cfg.Serial.Condition, env)
+			if err != nil {
+				return nil, fmt.Errorf("serial condition for %s %s: %w", device.Name, port.Name, err)
+			}
+
+			if !ok {
+				continue
+			}
+		}
+
+		envSessions, err := i.getSessions(env, data)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, envSessions...)
+	}
+
+	return sessions, nil
+}
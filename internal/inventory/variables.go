@@ -0,0 +1,24 @@
+package inventory
+
+import (
+	"fmt"
+
+	"github.com/jysk-network/netbox-securecrt-inventory/internal/config"
+	"github.com/jysk-network/netbox-securecrt-inventory/pkg/evaluator"
+)
+
+// applyVariables evaluates the user defined variables in order, so each one can refer to the earlier ones
+func applyVariables(eval *evaluator.Evaluator, variables []config.ConfigVariable, env *evaluator.Environment, trace *explainTrace) error {
+	env.Vars = make(map[string]interface{}, len(variables))
+	for _, variable := range variables {
+		val, err := eval.EvaluateResult(variable.Value, env)
+		if err != nil {
+			return fmt.Errorf("variable %s on %s: %w", variable.Name, env.DeviceName, err)
+		}
+
+		trace.add("variable %s: %q => %#v", variable.Name, variable.Value, val)
+		env.Vars[variable.Name] = val
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package inventory
+
+import (
+	"fmt"
+
+	"github.com/jysk-network/netbox-securecrt-inventory/internal/config"
+	"github.com/jysk-network/netbox-securecrt-inventory/pkg/evaluator"
+)
+
+const DEFAULT_VARIANT = "default"
+
+// getVariantEnvironments returns the environment for the default session, and a copy of it for each
+// variant whose condition matches. Console and serial sessions only get the default session, as the
+// variant port and protocol are meant for the device itself, not the console server or serial port.
+func getVariantEnvironments(eval *evaluator.Evaluator, variants []config.ConfigSessionVariant, env *evaluator.Environment, trace *explainTrace) ([]*evaluator.Environment, error) {
+	env.Variant = DEFAULT_VARIANT
+	envs := []*evaluator.Environment{env}
+	if env.IsConsoleSession || env.IsSerialSession {
+		if len(variants) > 0 {
+			trace.add("variants are skipped for console and serial sessions")
+		}
+		return envs, nil
+	}
+
+	for _, variant := range variants {
+		if variant.Condition != "" {
+			shouldAdd, err := eval.EvaluateCondition(variant.Condition, env)
+			if err != nil {
+				return nil, fmt.Errorf("variant %s: %w", variant.Name, err)
+			}
+
+			trace.add("variant %s: %s => %t", variant.Name, variant.Condition, shouldAdd)
+			if !shouldAdd {
+				continue
+			}
+		}
+
+		variantEnv := *env
+		variantEnv.Variant = variant.Name
+		envs = append(envs, &variantEnv)
+	}
+
+	return envs, nil
+}
+
+// applyVariant applies the variant port, protocol, credential and device name suffix, it runs after
+// the overrides so they apply the same way to every variant of a device, and can't replace the variant settings
+func applyVariant(eval *evaluator.Evaluator, variants []config.ConfigSessionVariant, env *evaluator.Environment, trace *explainTrace) error {
+	for _, variant := range variants {
+		if variant.Name != env.Variant {
+			continue
+		}
+
+		if variant.Port != 0 {
+			env.DevicePort = variant.Port
+		}
+
+		if variant.ConnectionProtocol != "" {
+			env.ConnectionProtocol = variant.ConnectionProtocol
+		}
+
+		if variant.Credential != "" {
+			env.Credential = variant.Credential
+		}
+
+		suffix, _, err := eval.EvaluateString(fmt.Sprintf("variants %s device_name_suffix", variant.Name), variant.DeviceNameSuffix, env)
+		if err != nil {
+			return err
+		}
+
+		env.DeviceName = env.DeviceName + suffix
+		trace.add("variant %s: port %d, connection_protocol %q, credential %q, device_name %q", variant.Name, env.DevicePort, env.ConnectionProtocol, env.Credential, env.DeviceName)
+	}
+
+	return nil
+}
@@ -3,6 +3,16 @@ package netbox
 import "errors"
 
 var (
-	ErrFailedToQuerySites   = errors.New("unable to get sites")
-	ErrFailedToQueryDevices = errors.New("unable to get devices")
+	ErrFailedToQuerySites              = errors.New("unable to get sites")
+	ErrFailedToQueryRegions            = errors.New("unable to get regions")
+	ErrFailedToQueryLocations          = errors.New("unable to get locations")
+	ErrFailedToQuerySiteGroups         = errors.New("unable to get site groups")
+	ErrFailedToQueryTenants            = errors.New("unable to get tenants")
+	ErrFailedToQueryTenantGroups       = errors.New("unable to get tenant groups")
+	ErrFailedToQueryClusters           = errors.New("unable to get clusters")
+	ErrFailedToQueryDevices            = errors.New("unable to get devices")
+	ErrFailedToQueryVirtualMachines    = errors.New("unable to get virtual machines")
+	ErrFailedToQueryConsoleServerPorts = errors.New("unable to get console server ports")
+	ErrFailedToQueryConsolePorts       = errors.New("unable to get console ports")
+	ErrFailedToQueryCableTrace         = errors.New("unable to get cable trace")
 )
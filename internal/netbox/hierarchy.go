@@ -0,0 +1,43 @@
+package netbox
+
+// Nested is implemented by the NetBox objects that can have a parent of the same type
+type Nested[T any] interface {
+	*T
+	GetId() int32
+	GetName() string
+	GetParent() *T
+}
+
+// GetHierarchy returns the object and all of its parents, starting at the root. Nested
+// objects returned by the API only hold one level, so parents are resolved from items.
+func GetHierarchy[T any, PT Nested[T]](items map[int32]T, item PT) []T {
+	hierarchy := make([]T, 0)
+	visited := make(map[int32]bool)
+	for item != nil && !visited[item.GetId()] {
+		visited[item.GetId()] = true
+		if fullItem, ok := items[item.GetId()]; ok {
+			item = &fullItem
+		}
+
+		hierarchy = append([]T{*item}, hierarchy...)
+		item = item.GetParent()
+	}
+
+	return hierarchy
+}
+
+func (r *Region) GetId() int32       { return r.Id }
+func (r *Region) GetName() string    { return r.Name }
+func (r *Region) GetParent() *Region { return r.Parent }
+
+func (l *Location) GetId() int32         { return l.Id }
+func (l *Location) GetName() string      { return l.Name }
+func (l *Location) GetParent() *Location { return l.Parent }
+
+func (g *SiteGroup) GetId() int32          { return g.Id }
+func (g *SiteGroup) GetName() string       { return g.Name }
+func (g *SiteGroup) GetParent() *SiteGroup { return g.Parent }
+
+func (g *TenantGroup) GetId() int32            { return g.Id }
+func (g *TenantGroup) GetName() string         { return g.Name }
+func (g *TenantGroup) GetParent() *TenantGroup { return g.Parent }
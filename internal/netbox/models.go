@@ -1,15 +1,19 @@
 package netbox
 
 type Region struct {
-	Id   int32  `json:"id"`
-	Name string `json:"name"`
-	Slug string `json:"slug"`
+	Id     int32   `json:"id"`
+	Name   string  `json:"name"`
+	Slug   string  `json:"slug"`
+	Parent *Region `json:"parent,omitempty"`
+	Depth  int32   `json:"_depth"`
 }
 
 type SiteGroup struct {
-	Id   int32  `json:"id"`
-	Name string `json:"name"`
-	Slug string `json:"slug"`
+	Id     int32      `json:"id"`
+	Name   string     `json:"name"`
+	Slug   string     `json:"slug"`
+	Parent *SiteGroup `json:"parent,omitempty"`
+	Depth  int32      `json:"_depth"`
 }
 
 type Site struct {
@@ -62,13 +66,25 @@ type VirtualChassis struct {
 	Description *string `json:"description,omitempty"`
 }
 
+type TenantGroup struct {
+	Id          int32        `json:"id"`
+	Url         string       `json:"url"`
+	Display     string       `json:"display"`
+	Name        string       `json:"name"`
+	Slug        string       `json:"slug"`
+	Description *string      `json:"description,omitempty"`
+	Parent      *TenantGroup `json:"parent,omitempty"`
+	Depth       int32        `json:"_depth"`
+}
+
 type Tenant struct {
-	Id          int32   `json:"id"`
-	Url         string  `json:"url"`
-	Display     string  `json:"display"`
-	Name        string  `json:"name"`
-	Slug        string  `json:"slug"`
-	Description *string `json:"description,omitempty"`
+	Id          int32        `json:"id"`
+	Url         string       `json:"url"`
+	Display     string       `json:"display"`
+	Name        string       `json:"name"`
+	Slug        string       `json:"slug"`
+	Description *string      `json:"description,omitempty"`
+	Group       *TenantGroup `json:"group,omitempty"`
 }
 
 type Platform struct {
@@ -82,13 +98,15 @@ type Platform struct {
 }
 
 type Location struct {
-	Id          int32   `json:"id"`
-	Url         string  `json:"url"`
-	Display     string  `json:"display"`
-	Name        string  `json:"name"`
-	Slug        string  `json:"slug"`
-	Description *string `json:"description,omitempty"`
-	Depth       int32   `json:"_depth"`
+	Id          int32     `json:"id"`
+	Url         string    `json:"url"`
+	Display     string    `json:"display"`
+	Name        string    `json:"name"`
+	Slug        string    `json:"slug"`
+	Description *string   `json:"description,omitempty"`
+	Parent      *Location `json:"parent,omitempty"`
+	Site        *Site     `json:"site,omitempty"`
+	Depth       int32     `json:"_depth"`
 }
 
 type Rack struct {
@@ -145,7 +163,7 @@ type DeviceWithConfigContext struct {
 	PrimaryIp4     *IPAddress      `json:"primary_ip4,omitempty"`
 	PrimaryIp6     *IPAddress      `json:"primary_ip6,omitempty"`
 	OobIp          *IPAddress      `json:"oob_ip,omitempty"`
-	Cluster        *IPAddress      `json:"cluster,omitempty"`
+	Cluster        *Cluster        `json:"cluster,omitempty"`
 	VirtualChassis *VirtualChassis `json:"virtual_chassis,omitempty"`
 	VcPosition     *int32          `json:"vc_position,omitempty"`
 	// Virtual chassis master election priority
@@ -160,14 +178,39 @@ type DeviceWithConfigContext struct {
 	AdditionalProperties map[string]interface{}
 }
 
-type Cluster struct {
+type ClusterGroup struct {
+	Id          int32   `json:"id"`
+	Url         string  `json:"url"`
+	Display     string  `json:"display"`
+	Name        string  `json:"name"`
+	Slug        string  `json:"slug"`
+	Description *string `json:"description,omitempty"`
+}
+
+type ClusterType struct {
 	Id          int32   `json:"id"`
 	Url         string  `json:"url"`
 	Display     string  `json:"display"`
 	Name        string  `json:"name"`
+	Slug        string  `json:"slug"`
 	Description *string `json:"description,omitempty"`
 }
 
+type Cluster struct {
+	Id          int32         `json:"id"`
+	Url         string        `json:"url"`
+	Display     string        `json:"display"`
+	Name        string        `json:"name"`
+	Description *string       `json:"description,omitempty"`
+	Type        *ClusterType  `json:"type,omitempty"`
+	Group       *ClusterGroup `json:"group,omitempty"`
+	Tenant      *Tenant       `json:"tenant,omitempty"`
+	// Site is only returned by NetBox versions before 4.2, newer versions use the scope
+	Site      *Site   `json:"site,omitempty"`
+	ScopeType *string `json:"scope_type,omitempty"`
+	ScopeId   *int32  `json:"scope_id,omitempty"`
+}
+
 type VirtualMachineWithConfigContext struct {
 	Id          int32         `json:"id"`
 	Url         string        `json:"url"`
@@ -205,7 +248,9 @@ type NestedDevice struct {
 
 type ConnectedEndpoint struct {
 	Id      int32        `json:"id"`
+	Url     string       `json:"url"`
 	Display string       `json:"display"`
+	Name    string       `json:"name"`
 	Device  NestedDevice `json:"device"`
 }
 
@@ -219,3 +264,20 @@ type ConsoleServerPort struct {
 	Device             NestedDevice           `json:"device"`
 	ConnectedEndpoints *[]ConnectedEndpoint   `json:"connected_endpoints,omitempty"`
 }
+
+type ConsolePortSpeed struct {
+	Value *int32  `json:"value,omitempty"`
+	Label *string `json:"label,omitempty"`
+}
+
+type ConsolePort struct {
+	Id                 int32                  `json:"id"`
+	Url                string                 `json:"url"`
+	Display            string                 `json:"display"`
+	Name               string                 `json:"name"`
+	Speed              *ConsolePortSpeed      `json:"speed,omitempty"`
+	Tags               []NestedTag            `json:"tags,omitempty"`
+	CustomFields       map[string]interface{} `json:"custom_fields,omitempty"`
+	Device             NestedDevice           `json:"device"`
+	ConnectedEndpoints *[]ConnectedEndpoint   `json:"connected_endpoints,omitempty"`
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strings"
 )
 
@@ -71,33 +72,39 @@ func (nb *NetBox) TestConnection() error {
 	return nil
 }
 
-func (nb *NetBox) GetSites() ([]Site, error) {
-	var results = make([]Site, 0)
+// getAll fetches every page of a list endpoint, the path may already contain query parameters
+func getAll[T any](nb *NetBox, path string, name string, queryErr error) ([]T, error) {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	var results = make([]T, 0)
 	hasMorePages := true
 	for hasMorePages {
 		currentCount := len(results)
-		req, err := nb.PrepareRequest("GET", fmt.Sprintf("/dcim/sites/?limit=%d&offset=%d", nb.limit, currentCount))
+		req, err := nb.PrepareRequest("GET", fmt.Sprintf("%s%slimit=%d&offset=%d", path, separator, nb.limit, currentCount))
 		if err != nil {
 			return results, err
 		}
 
 		response, err := nb.httpClient.Do(req)
 		if err != nil {
-			slog.Error("Failed to get sites from netbox", slog.String("error", err.Error()))
-			return nil, ErrFailedToQuerySites
+			slog.Error(fmt.Sprintf("Failed to get %s from netbox", name), slog.String("error", err.Error()))
+			return nil, queryErr
 		}
 		defer response.Body.Close()
 		body, err := io.ReadAll(response.Body)
 		if err != nil {
-			slog.Error("Failed to read body from sites request", slog.String("error", err.Error()))
-			return nil, ErrFailedToQuerySites
+			slog.Error(fmt.Sprintf("Failed to read body from %s request", name), slog.String("error", err.Error()))
+			return nil, queryErr
 		}
 
-		var data NetBoxRespone[Site]
+		var data NetBoxRespone[T]
 		err = json.Unmarshal(body, &data)
 		if err != nil {
-			slog.Error("Failed to parse sites from netbox", slog.String("error", err.Error()))
-			return nil, ErrFailedToQuerySites
+			slog.Error(fmt.Sprintf("Failed to parse %s from netbox", name), slog.String("error", err.Error()))
+			return nil, queryErr
 		}
 
 		results = append(results, data.Results...)
@@ -106,123 +113,119 @@ func (nb *NetBox) GetSites() ([]Site, error) {
 		}
 	}
 
-	slog.Info("Retrieved sites", slog.Int("count", len(results)))
+	slog.Info(fmt.Sprintf("Retrieved %s", name), slog.Int("count", len(results)))
 	return results, nil
 }
 
-func (nb *NetBox) GetDevices() ([]DeviceWithConfigContext, error) {
-	var results = make([]DeviceWithConfigContext, 0)
-	hasMorePages := true
-	for hasMorePages {
-		currentCount := len(results)
-		req, err := nb.PrepareRequest("GET", fmt.Sprintf("/dcim/devices/?has_primary_ip=true&limit=%d&offset=%d", nb.limit, currentCount))
-		if err != nil {
-			return results, err
-		}
+// get fetches a single object or a non paginated list from the API
+func get[T any](nb *NetBox, path string, name string, queryErr error) (*T, error) {
+	req, err := nb.PrepareRequest("GET", path)
+	if err != nil {
+		return nil, err
+	}
 
-		response, err := nb.httpClient.Do(req)
-		if err != nil {
-			slog.Error("Failed to get sites from netbox", slog.String("error", err.Error()))
-			return nil, ErrFailedToQuerySites
-		}
-		defer response.Body.Close()
-		body, err := io.ReadAll(response.Body)
-		if err != nil {
-			slog.Error("Failed to read body from sites request", slog.String("error", err.Error()))
-			return nil, ErrFailedToQuerySites
-		}
+	response, err := nb.httpClient.Do(req)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to get %s from netbox", name), slog.String("error", err.Error()))
+		return nil, queryErr
+	}
+	defer response.Body.Close()
 
-		var data NetBoxRespone[DeviceWithConfigContext]
-		err = json.Unmarshal(body, &data)
-		if err != nil {
-			slog.Error("Failed to parse sites from netbox", slog.String("error", err.Error()))
-			return nil, ErrFailedToQuerySites
-		}
+	if response.StatusCode != http.StatusOK {
+		slog.Error(fmt.Sprintf("Failed to get %s from netbox", name), slog.Int("status", response.StatusCode))
+		return nil, queryErr
+	}
 
-		results = append(results, data.Results...)
-		if len(data.Results) < int(nb.limit) {
-			hasMorePages = false
-		}
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to read body from %s request", name), slog.String("error", err.Error()))
+		return nil, queryErr
 	}
 
-	slog.Info("Retrieved devices", slog.Int("count", len(results)))
-	return results, nil
+	var data T
+	err = json.Unmarshal(body, &data)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to parse %s from netbox", name), slog.String("error", err.Error()))
+		return nil, queryErr
+	}
+
+	return &data, nil
 }
 
-func (nb *NetBox) GetVirtualMachines() ([]VirtualMachineWithConfigContext, error) {
-	var results = make([]VirtualMachineWithConfigContext, 0)
-	hasMorePages := true
-	for hasMorePages {
-		currentCount := len(results)
-		req, err := nb.PrepareRequest("GET", fmt.Sprintf("/virtualization/virtual-machines/?has_primary_ip=true&limit=%d&offset=%d", nb.limit, currentCount))
-		if err != nil {
-			return results, err
-		}
+func (nb *NetBox) GetSites() ([]Site, error) {
+	return getAll[Site](nb, "/dcim/sites/", "sites", ErrFailedToQuerySites)
+}
 
-		response, err := nb.httpClient.Do(req)
-		if err != nil {
-			slog.Error("Failed to get sites from netbox", slog.String("error", err.Error()))
-			return nil, ErrFailedToQuerySites
-		}
-		defer response.Body.Close()
-		body, err := io.ReadAll(response.Body)
-		if err != nil {
-			slog.Error("Failed to read body from sites request", slog.String("error", err.Error()))
-			return nil, ErrFailedToQuerySites
-		}
+func (nb *NetBox) GetRegions() ([]Region, error) {
+	return getAll[Region](nb, "/dcim/regions/", "regions", ErrFailedToQueryRegions)
+}
 
-		var data NetBoxRespone[VirtualMachineWithConfigContext]
-		err = json.Unmarshal(body, &data)
-		if err != nil {
-			slog.Error("Failed to parse sites from netbox", slog.String("error", err.Error()))
-			return nil, ErrFailedToQuerySites
-		}
+func (nb *NetBox) GetLocations() ([]Location, error) {
+	return getAll[Location](nb, "/dcim/locations/", "locations", ErrFailedToQueryLocations)
+}
 
-		results = append(results, data.Results...)
-		if len(data.Results) < int(nb.limit) {
-			hasMorePages = false
-		}
-	}
+func (nb *NetBox) GetSiteGroups() ([]SiteGroup, error) {
+	return getAll[SiteGroup](nb, "/dcim/site-groups/", "site groups", ErrFailedToQuerySiteGroups)
+}
 
-	slog.Info("Retrieved virtual machines", slog.Int("count", len(results)))
-	return results, nil
+func (nb *NetBox) GetTenants() ([]Tenant, error) {
+	return getAll[Tenant](nb, "/tenancy/tenants/", "tenants", ErrFailedToQueryTenants)
+}
+
+func (nb *NetBox) GetTenantGroups() ([]TenantGroup, error) {
+	return getAll[TenantGroup](nb, "/tenancy/tenant-groups/", "tenant groups", ErrFailedToQueryTenantGroups)
+}
+
+func (nb *NetBox) GetClusters() ([]Cluster, error) {
+	return getAll[Cluster](nb, "/virtualization/clusters/", "clusters", ErrFailedToQueryClusters)
+}
+
+func (nb *NetBox) GetDevices() ([]DeviceWithConfigContext, error) {
+	return getAll[DeviceWithConfigContext](nb, "/dcim/devices/?has_primary_ip=true", "devices", ErrFailedToQueryDevices)
+}
+
+func (nb *NetBox) GetDevice(id int32) (*DeviceWithConfigContext, error) {
+	return get[DeviceWithConfigContext](nb, fmt.Sprintf("/dcim/devices/%d/", id), "device", ErrFailedToQueryDevices)
+}
+
+func (nb *NetBox) GetDevicesByName(name string) ([]DeviceWithConfigContext, error) {
+	return getAll[DeviceWithConfigContext](nb, "/dcim/devices/?name="+url.QueryEscape(name), "devices", ErrFailedToQueryDevices)
+}
+
+func (nb *NetBox) GetVirtualMachine(id int32) (*VirtualMachineWithConfigContext, error) {
+	return get[VirtualMachineWithConfigContext](nb, fmt.Sprintf("/virtualization/virtual-machines/%d/", id), "virtual machine", ErrFailedToQueryVirtualMachines)
+}
+
+func (nb *NetBox) GetVirtualMachinesByName(name string) ([]VirtualMachineWithConfigContext, error) {
+	return getAll[VirtualMachineWithConfigContext](nb, "/virtualization/virtual-machines/?name="+url.QueryEscape(name), "virtual machines", ErrFailedToQueryVirtualMachines)
+}
+
+func (nb *NetBox) GetVirtualMachines() ([]VirtualMachineWithConfigContext, error) {
+	return getAll[VirtualMachineWithConfigContext](nb, "/virtualization/virtual-machines/?has_primary_ip=true", "virtual machines", ErrFailedToQueryVirtualMachines)
 }
 
 func (nb *NetBox) GetConsoleServerPorts() ([]ConsoleServerPort, error) {
-	var results = make([]ConsoleServerPort, 0)
-	hasMorePages := true
-	for hasMorePages {
-		currentCount := len(results)
-		req, err := nb.PrepareRequest("GET", fmt.Sprintf("/dcim/console-server-ports?limit=%d&offset=%d", nb.limit, currentCount))
-		if err != nil {
-			return results, err
-		}
+	return getAll[ConsoleServerPort](nb, "/dcim/console-server-ports/", "console server ports", ErrFailedToQueryConsoleServerPorts)
+}
 
-		response, err := nb.httpClient.Do(req)
-		if err != nil {
-			slog.Error("Failed to get sites from netbox", slog.String("error", err.Error()))
-			return nil, ErrFailedToQuerySites
-		}
-		defer response.Body.Close()
-		body, err := io.ReadAll(response.Body)
-		if err != nil {
-			slog.Error("Failed to read body from sites request", slog.String("error", err.Error()))
-			return nil, ErrFailedToQuerySites
-		}
+func (nb *NetBox) GetConsolePorts() ([]ConsolePort, error) {
+	return getAll[ConsolePort](nb, "/dcim/console-ports/", "console ports", ErrFailedToQueryConsolePorts)
+}
 
-		var data NetBoxRespone[ConsoleServerPort]
-		err = json.Unmarshal(body, &data)
-		if err != nil {
-			slog.Error("Failed to parse sites from netbox", slog.String("error", err.Error()))
-			return nil, ErrFailedToQuerySites
-		}
+func (nb *NetBox) GetConsoleServerPortTrace(id int32) (CableTrace, error) {
+	trace, err := get[CableTrace](nb, fmt.Sprintf("/dcim/console-server-ports/%d/trace/", id), "console server port trace", ErrFailedToQueryCableTrace)
+	if err != nil {
+		return nil, err
+	}
 
-		results = append(results, data.Results...)
-		if len(data.Results) < int(nb.limit) {
-			hasMorePages = false
-		}
+	return *trace, nil
+}
+
+func (nb *NetBox) GetConsolePortTrace(id int32) (CableTrace, error) {
+	trace, err := get[CableTrace](nb, fmt.Sprintf("/dcim/console-ports/%d/trace/", id), "console port trace", ErrFailedToQueryCableTrace)
+	if err != nil {
+		return nil, err
 	}
 
-	slog.Info("Retrieved console server ports", slog.Int("count", len(results)))
-	return results, nil
+	return *trace, nil
 }
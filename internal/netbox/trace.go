@@ -0,0 +1,103 @@
+package netbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type Cable struct {
+	Id      int32  `json:"id"`
+	Url     string `json:"url"`
+	Display string `json:"display"`
+	Label   string `json:"label"`
+}
+
+type CableTermination struct {
+	Id      int32         `json:"id"`
+	Url     string        `json:"url"`
+	Display string        `json:"display"`
+	Name    string        `json:"name"`
+	Device  *NestedDevice `json:"device,omitempty"`
+}
+
+// CableTraceSegment is one hop of a cable trace, the API returns each hop as
+// a [near end terminations, cable, far end terminations] array
+type CableTraceSegment struct {
+	NearEnds []CableTermination
+	Cable    *Cable
+	FarEnds  []CableTermination
+}
+
+type CableTrace []CableTraceSegment
+
+func (s *CableTraceSegment) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	if len(raw) != 3 {
+		return fmt.Errorf("unexpected cable trace segment with %d items", len(raw))
+	}
+
+	err = json.Unmarshal(raw[0], &s.NearEnds)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(raw[1], &s.Cable)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw[2], &s.FarEnds)
+}
+
+func (s CableTraceSegment) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{s.NearEnds, s.Cable, s.FarEnds})
+}
+
+// Endpoints returns the terminations at the far end of the trace, it's empty
+// when the path is not complete
+func (t CableTrace) Endpoints() []CableTermination {
+	if len(t) == 0 || t[len(t)-1].Cable == nil {
+		return nil
+	}
+
+	return t[len(t)-1].FarEnds
+}
+
+// Summary returns a readable version of the trace, ex:
+// "cs01 Port 1 > #12 > pp01 Front 1 | pp01 Rear 1 > #13 > sw01 Console"
+func (t CableTrace) Summary() string {
+	segments := make([]string, 0, len(t))
+	for _, segment := range t {
+		hops := []string{getTerminationsSummary(segment.NearEnds)}
+		if segment.Cable != nil {
+			hops = append(hops, segment.Cable.Display)
+		}
+
+		if len(segment.FarEnds) > 0 {
+			hops = append(hops, getTerminationsSummary(segment.FarEnds))
+		}
+
+		segments = append(segments, strings.Join(hops, " > "))
+	}
+
+	return strings.Join(segments, " | ")
+}
+
+func getTerminationsSummary(terminations []CableTermination) string {
+	names := make([]string, 0, len(terminations))
+	for _, termination := range terminations {
+		if termination.Device != nil {
+			names = append(names, fmt.Sprintf("%s %s", termination.Device.Name, termination.Name))
+		} else {
+			names = append(names, termination.Display)
+		}
+	}
+
+	return strings.Join(names, ", ")
+}
@@ -2,12 +2,14 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/jysk-network/netbox-securecrt-inventory/internal/config"
 	"github.com/jysk-network/netbox-securecrt-inventory/internal/gui"
@@ -25,6 +27,32 @@ func main() {
 		return
 	}
 
+	// open resolves the NetBox object of a session and opens it in the browser, ex: securecrt-inventory open NetBox/Stores/sw01
+	// it's run from a SecureCRT button, so it's handled before the config is saved and the log of the systray is truncated
+	if flag.Arg(0) == "open" {
+		err := openSession(strings.Join(flag.Args()[1:], " "))
+		if err != nil {
+			dialog.Message("Error: %v", err).Title("Open Error").Error()
+		}
+		return
+	}
+
+	// explain and playground run from the command line without the systray, ex: securecrt-inventory explain sw01
+	// they can run next to the systray, so they're also handled before the config is saved and the log is truncated
+	if command := flag.Arg(0); command == "explain" || command == "playground" {
+		if !gui.AttachConsole() {
+			dialog.Message("The %s command needs to be run from a terminal, ex: start /wait securecrt-inventory.exe %s <device>", command, command).Title("Command Error").Error()
+			return
+		}
+
+		err := runCommand(cfgPath, command, strings.Join(flag.Args()[1:], " "))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	cfg, err := config.NewConfig(cfgPath)
 	if err != nil {
 		dialog.Message("Error: %v", err).Title("Config Error").Error()
@@ -51,14 +79,7 @@ func main() {
 		return
 	}
 
-	logLevel := slog.LevelError
-	if cfg.LogLevel == "DEBUG" {
-		logLevel = slog.LevelDebug
-	}
-	if cfg.LogLevel == "INFO" {
-		logLevel = slog.LevelInfo
-	}
-	logger := slog.New(slog.NewTextHandler(file, &slog.HandlerOptions{Level: logLevel}))
+	logger := slog.New(slog.NewTextHandler(file, &slog.HandlerOptions{Level: getLogLevel(cfg)}))
 	slog.SetDefault(logger)
 
 	// setup securecrt config builder, and validate it's installed
@@ -113,6 +134,17 @@ func main() {
 				}
 			}
 
+			if menuItem == "explain" {
+				go func() {
+					explainPath := filepath.Join(filepath.Dir(logPath), "explain.txt")
+					err := explain(invClient, explainPath)
+					if err != nil {
+						slog.Error("failed to explain device", slog.String("error", err.Error()))
+						dialog.Message("Error: %v", err).Title("Explain Error").Error()
+					}
+				}()
+			}
+
 			if menuItem == "quit" {
 				systray.Quit()
 			}
@@ -124,6 +156,90 @@ func main() {
 	cancelCtx()
 }
 
+func getLogLevel(cfg *config.Config) slog.Level {
+	switch cfg.LogLevel {
+	case "DEBUG":
+		return slog.LevelDebug
+	case "INFO":
+		return slog.LevelInfo
+	}
+	return slog.LevelError
+}
+
+// runCommand runs a command line command, the config is read without saving it and the log is written to stderr
+func runCommand(cfgPath string, command string, query string) error {
+	cfg, err := config.ReadConfig(cfgPath)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: getLogLevel(cfg)})))
+
+	scrt, err := securecrt.New(cfg.RootPath)
+	if err != nil {
+		return err
+	}
+
+	nb := netbox.New(cfg.NetboxUrl, cfg.NetboxToken, context.Background())
+	invClient := inventory.New(cfg, nb, scrt, func(state string, message string) {})
+	if command == "playground" {
+		// tab completion needs the terminal in raw mode, the input is read line by line without it, ex: when it's redirected
+		restore, err := gui.MakeRaw(os.Stdin)
+		if err == nil {
+			defer restore()
+		}
+		return invClient.Playground(query, os.Stdin, os.Stdout, err == nil)
+	}
+
+	explanation, err := invClient.Explain(query)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(explanation)
+	return nil
+}
+
+// explain asks for a device, and opens a file with the explanation of its sessions
+func explain(invClient *inventory.InventorySync, explainPath string) error {
+	query, ok, err := gui.Prompt("Explain Device", "Device or virtual machine name, or NetBox ID:")
+	if err != nil || !ok {
+		return err
+	}
+
+	explanation, err := invClient.Explain(query)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(explainPath, []byte(explanation), 0644)
+	if err != nil {
+		return err
+	}
+
+	return openFile(explainPath)
+}
+
+// openSession prints the NetBox object of a session file, and opens it in the browser
+func openSession(file string) error {
+	// the session path is relative to the SecureCRT sessions folder, so the root path isn't needed
+	scrt, err := securecrt.New("")
+	if err != nil {
+		return err
+	}
+
+	object, err := scrt.GetNetBoxObject(file)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s %d %s\n", object.Type, object.Id, object.Url)
+	if object.Url == "" {
+		return fmt.Errorf("no netbox url for %s %d", object.Type, object.Id)
+	}
+
+	return openFile(object.Url)
+}
+
 func openFile(file string) error {
 	var err error
 	switch runtime.GOOS {
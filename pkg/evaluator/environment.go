@@ -2,6 +2,7 @@ package evaluator
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 
 	"github.com/jysk-network/netbox-securecrt-inventory/internal/netbox"
@@ -9,8 +10,10 @@ import (
 
 type Environment struct {
 	SessionType                string `expr:"session_type"`
+	Variant                    string `expr:"variant"`
 	Description                string `expr:"description"`
 	Credential                 string `expr:"credential"`
+	Username                   string `expr:"username"`
 	Path                       string `expr:"path"`
 	PathTemplate               string `expr:"path_template"`
 	DeviceName                 string `expr:"device_name"`
@@ -19,29 +22,162 @@ type Environment struct {
 	FirewallTemplate           string `expr:"firewall_template"`
 	ConnectionProtocol         string `expr:"connection_protocol"`
 	ConnectionProtocolTemplate string `expr:"connection_protocol_template"`
+	DescriptionTemplate        string `expr:"description_template"`
 	DeviceRole                 string `expr:"device_role"`
 	DeviceType                 string `expr:"device_type"`
 	DeviceIP                   string `expr:"device_ip"`
 	DevicePort                 int    `expr:"device_port"`
+	DevicePlatform             string `expr:"device_platform"`
+	DevicePlatformSlug         string `expr:"device_platform_slug"`
+	DeviceStatus               string `expr:"device_status"`
+	DeviceSerial               string `expr:"device_serial"`
+	DeviceAssetTag             string `expr:"device_asset_tag"`
+	DeviceDescription          string `expr:"device_description"`
+	DeviceComments             string `expr:"device_comments"`
+	NetboxUrl                  string `expr:"netbox_url"`
+	NetboxObjectType           string `expr:"netbox_object_type"`
+	NetboxObjectId             int    `expr:"netbox_object_id"`
+	SyncTime                   string `expr:"sync_time"`
 	RegionName                 string `expr:"region_name"`
+	RegionPath                 string `expr:"region_path"`
+	RegionRoot                 string `expr:"region_root"`
 	TenantName                 string `expr:"tenant_name"`
+	TenantSlug                 string `expr:"tenant_slug"`
+	TenantGroup                string `expr:"tenant_group"`
+	TenantGroupPath            string `expr:"tenant_group_path"`
 	SiteName                   string `expr:"site_name"`
+	SiteSlug                   string `expr:"site_slug"`
 	SiteGroup                  string `expr:"site_group"`
+	SiteGroupName              string `expr:"site_group_name"`
+	SiteGroupPath              string `expr:"site_group_path"`
 	SiteAddress                string `expr:"site_address"`
+	LocationName               string `expr:"location_name"`
+	LocationPath               string `expr:"location_path"`
+	RackName                   string `expr:"rack_name"`
+	ClusterName                string `expr:"cluster_name"`
+	ClusterGroup               string `expr:"cluster_group"`
+	ClusterType                string `expr:"cluster_type"`
 	VirtualChassisName         string `expr:"virtual_chassis_name"`
 	IsConsoleSession           bool   `expr:"is_console_session"`
+	ConsoleServerName          string `expr:"console_server_name"`
 	ConsoleServerPort          string `expr:"console_server_port"`
+	ConsoleServerPortId        int    `expr:"console_server_port_id"`
+	ConsoleServerPortNumber    int    `expr:"console_server_port_number"`
+	ConsoleServerManufacturer  string `expr:"console_server_manufacturer"`
+	ConsoleServerPlatform      string `expr:"console_server_platform"`
+	ConsoleProfile             string `expr:"console_profile"`
+	ConsoleUsername            string `expr:"console_username"`
+	ConsolePort                string `expr:"console_port"`
+	ConsolePortId              int    `expr:"console_port_id"`
+	ConsoleTrace               string `expr:"console_trace"`
+	ConsoleSessionPath         string `expr:"console_session_path"`
+	IsSerialSession            bool   `expr:"is_serial_session"`
+	SerialPort                 string `expr:"serial_port"`
+	SerialBaudRate             int    `expr:"serial_baud_rate"`
+	ConsolePortConnected       bool   `expr:"console_port_connected"`
 
-	Device interface{} `expr:"device"`
-	Site   interface{} `expr:"site"`
+	CustomFields  map[string]interface{} `expr:"custom_fields"`
+	Tags          []string               `expr:"tags"`
+	RegionNames   []string               `expr:"region_names"`
+	LocationNames []string               `expr:"location_names"`
+	Vars          map[string]interface{} `expr:"vars"`
+	Lookup        LookupFunc             `expr:"lookup" json:"-"`
+	// SessionKeys are extra SecureCRT session keys set by overrides, by their type and name, ex: D:Idle Timeout
+	SessionKeys map[string]string `expr:"session_keys"`
+
+	Device  interface{} `expr:"device"`
+	Site    interface{} `expr:"site"`
+	Tenant  interface{} `expr:"tenant"`
+	Cluster interface{} `expr:"cluster"`
+	Trace   interface{} `expr:"trace"`
+}
+
+// Field is a named value of the environment, as used in templates and expressions
+type Field struct {
+	Name  string
+	Value interface{}
+	// Object is set for the NetBox objects and functions, which are only usable from expressions
+	Object bool
+}
+
+// Fields returns the fields of the environment in declaration order, it's not a method
+// as methods of the environment are callable from expressions
+func Fields(env *Environment) []Field {
+	v := reflect.ValueOf(env).Elem()
+	t := v.Type()
+	fields := make([]Field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("expr")
+		if name == "" {
+			continue
+		}
+
+		fields = append(fields, Field{
+			Name:   name,
+			Value:  v.Field(i).Interface(),
+			Object: t.Field(i).Type.Kind() == reflect.Interface || t.Field(i).Type.Kind() == reflect.Func,
+		})
+	}
+	return fields
+}
+
+// FieldKind returns the kind of a field that can be set with SetField, ok is false for unknown
+// fields and fields that are not a string, number or bool
+func FieldKind(name string) (kind reflect.Kind, ok bool) {
+	i, ok := fieldIndex[name]
+	if !ok {
+		return reflect.Invalid, false
+	}
+
+	kind = reflect.TypeOf(Environment{}).Field(i).Type.Kind()
+	return kind, kind == reflect.String || kind == reflect.Int || kind == reflect.Bool
 }
 
+// SetField sets a field by its name, the value is converted to the type of the field with
+// AsString, AsInt or AsBool, and nil keeps the current value
+func SetField(env *Environment, name string, value any) error {
+	kind, ok := FieldKind(name)
+	if !ok {
+		return fmt.Errorf("unknown field '%s'", name)
+	}
+
+	field := reflect.ValueOf(env).Elem().Field(fieldIndex[name])
+	switch kind {
+	case reflect.String:
+		s, ok, err := AsString(value)
+		if err != nil || !ok {
+			return err
+		}
+		field.SetString(s)
+	case reflect.Int:
+		i, ok, err := AsInt(value)
+		if err != nil || !ok {
+			return err
+		}
+		field.SetInt(int64(i))
+	case reflect.Bool:
+		b, ok, err := AsBool(value)
+		if err != nil || !ok {
+			return err
+		}
+		field.SetBool(b)
+	}
+	return nil
+}
+
+// FindTag returns the value of the first "label:value" tag, or the name of a tag named label, or nil.
+//
+// Deprecated: use HasTag and TagValue, FindTag is kept for existing configs
 func (Environment) FindTag(tags []netbox.NestedTag, label string) *string {
 	for i := 0; i < len(tags); i++ {
-		if strings.Contains(tags[i].Name, label) {
-			result := strings.TrimPrefix(tags[i].Name, fmt.Sprintf("%s:", label))
+		if tags[i].Name == label {
+			result := tags[i].Name
 			return &result
 		}
+
+		if value, ok := strings.CutPrefix(tags[i].Name, label+":"); ok {
+			return &value
+		}
 	}
 
 	return nil
@@ -4,101 +4,180 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"reflect"
-	"strings"
-
-	"github.com/expr-lang/expr"
-	"github.com/expr-lang/expr/vm"
+	"regexp"
+	"sync"
+	"sync/atomic"
 )
 
-var compiledConditions map[string]*vm.Program = make(map[string]*vm.Program)
+// generations counts the evaluators created, each config load creates a new generation
+var generations atomic.Uint64
+
+// MAX_DYNAMIC_TEMPLATES is the size of the cache for templates that are not from the config, ex: a nested
+// console session path rendered for each device, and of the cache for regular expressions, as patterns
+// can be built from the device, the caches are cleared when they are full
+const MAX_DYNAMIC_TEMPLATES = 1000
+
+// Evaluator evaluates templates and expressions, compiled templates are cached and the
+// cache is safe for concurrent use. An evaluator belongs to one config generation, so
+// templates from a previous config are dropped with the evaluator.
+type Evaluator struct {
+	mu         sync.RWMutex
+	generation uint64
+	templates  map[string]*Template
+	dynamic    map[string]*Template
+	regexes    map[string]*regexp.Regexp
+	lookups    map[string]map[string]string
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+}
 
-func EvaluateCondition(condition string, env *Environment) (bool, error) {
-	output, err := EvaluateResult(condition, env)
-	if err != nil {
-		return false, err
+// New returns an evaluator with all templates precompiled, errors for invalid templates
+// are returned together, and the valid templates are still cached. The lookup tables are
+// used by lookup() in expressions and {lookup:table:key_var} in templates.
+func New(templates []string, lookups map[string]map[string]string) (*Evaluator, error) {
+	e := &Evaluator{
+		generation: generations.Add(1),
+		templates:  make(map[string]*Template, len(templates)),
+		dynamic:    make(map[string]*Template),
+		regexes:    make(map[string]*regexp.Regexp),
+		lookups:    lookups,
 	}
 
-	val, ok := output.(bool)
-	if !ok {
-		slog.Error("Condition should return true or false", slog.Any("result", val))
-		return false, errors.New("condition should return true or false")
+	var errs []error
+	for _, template := range templates {
+		if _, ok := e.templates[template]; ok {
+			continue
+		}
+
+		t, err := compile(template, e.getRegex)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		e.templates[template] = t
 	}
 
-	return val, nil
+	slog.Debug("Precompiled templates", slog.Uint64("generation", e.generation), slog.Int("count", len(e.templates)))
+	return e, errors.Join(errs...)
 }
 
-func EvaluateResult(condition string, env *Environment) (any, error) {
-	if !strings.Contains(condition, "{{") {
-		return ApplyTemplate(condition, env), nil
+func (e *Evaluator) getTemplate(template string) (*Template, error) {
+	e.mu.RLock()
+	t, ok := e.templates[template]
+	if !ok {
+		t, ok = e.dynamic[template]
+	}
+	e.mu.RUnlock()
+	if ok {
+		e.hits.Add(1)
+		return t, nil
 	}
 
-	start, end := getStringBeforeAfter(condition, "{{", "}}")
-
-	condition = getStringInBetween(condition, "{{", "}}")
-	condition = strings.Trim(condition, " ")
+	e.misses.Add(1)
+	t, err := compile(template, e.getRegex)
+	if err != nil {
+		slog.Error("Failed to compile template", slog.String("template", template), slog.String("error", err.Error()))
+		return nil, err
+	}
 
-	// compile and cache conditions
-	if compiledConditions[condition] == nil {
-		program, err := expr.Compile(condition)
-		if err != nil {
-			slog.Error("Failed to compile condition", slog.String("error", err.Error()))
-			return false, err
-		}
+	// only the config templates are kept, as the dynamic templates grow with the number of devices
+	e.mu.Lock()
+	if len(e.dynamic) >= MAX_DYNAMIC_TEMPLATES {
+		e.dynamic = make(map[string]*Template)
+	}
+	e.dynamic[template] = t
+	e.mu.Unlock()
+	return t, nil
+}
 
-		compiledConditions[condition] = program
+// getRegex returns the compiled regular expression for the Regex functions, most patterns are literals
+// in the config, so they are compiled once per sync
+func (e *Evaluator) getRegex(pattern string) (*regexp.Regexp, error) {
+	e.mu.RLock()
+	re, ok := e.regexes[pattern]
+	e.mu.RUnlock()
+	if ok {
+		return re, nil
 	}
 
-	output, err := expr.Run(compiledConditions[condition], env)
+	re, err := regexp.Compile(pattern)
 	if err != nil {
-		slog.Error("Failed to run condition", slog.String("error", err.Error()))
-		return false, err
+		return nil, err
 	}
 
-	if start != "" || end != "" {
-		output = fmt.Sprintf("%s%s%s", start, output, end)
+	e.mu.Lock()
+	if len(e.regexes) >= MAX_DYNAMIC_TEMPLATES {
+		e.regexes = make(map[string]*regexp.Regexp)
 	}
+	e.regexes[pattern] = re
+	e.mu.Unlock()
+	return re, nil
+}
 
-	slog.Debug("Evaluation Result", slog.String("device", env.DeviceName), slog.String("condition", condition), slog.Any("result", output))
-	return output, nil
+// ClearDynamic drops the cached templates that are not from the config and the regular expressions,
+// it's called after each sync
+func (e *Evaluator) ClearDynamic() {
+	e.mu.Lock()
+	e.dynamic = make(map[string]*Template)
+	e.regexes = make(map[string]*regexp.Regexp)
+	e.mu.Unlock()
 }
 
-func ApplyTemplate(template string, env *Environment) string {
-	oldTemplate := template
-	v := reflect.ValueOf(env).Elem()
-	for i := 0; i < v.NumField(); i++ {
-		if v.Field(i).Kind() == reflect.String {
-			tag := v.Type().Field(i).Tag.Get("expr")
-			template = strings.ReplaceAll(template, fmt.Sprintf("{%s}", tag), v.Field(i).String())
-		}
+func (e *Evaluator) EvaluateCondition(condition string, env *Environment) (bool, error) {
+	output, err := e.EvaluateResult(condition, env)
+	if err != nil {
+		return false, err
+	}
+
+	val, ok := output.(bool)
+	if !ok {
+		slog.Error("Condition should return true or false", slog.Any("result", val))
+		return false, errors.New("condition should return true or false")
 	}
 
-	slog.Debug("Evaluation Template Result", slog.String("device", env.DeviceName), slog.String("template", oldTemplate), slog.String("result", template))
-	return template
+	return val, nil
 }
 
-func getStringBeforeAfter(str string, start string, end string) (string, string) {
-	s := strings.Index(str, start)
-	if s == -1 {
-		return "", ""
+func (e *Evaluator) EvaluateResult(template string, env *Environment) (any, error) {
+	t, err := e.getTemplate(template)
+	if err != nil {
+		return false, err
 	}
-	s += len(start)
-	e := strings.Index(str[s:], end)
-	if e == -1 {
-		return "", ""
+
+	output, err := t.Evaluate(env)
+	if err != nil {
+		slog.Error("Failed to run template", slog.String("template", template), slog.String("error", err.Error()))
+		return false, err
 	}
-	return str[:s-2], str[s+e+2:]
+
+	slog.Debug("Evaluation Result", slog.String("device", env.DeviceName), slog.String("template", template), slog.Any("result", output))
+	return output, nil
 }
 
-func getStringInBetween(str string, start string, end string) string {
-	s := strings.Index(str, start)
-	if s == -1 {
+// ApplyTemplate returns the result of the template as a string, it's empty if the template is invalid
+func (e *Evaluator) ApplyTemplate(template string, env *Environment) string {
+	output, err := e.EvaluateResult(template, env)
+	if err != nil || output == nil {
 		return ""
 	}
-	s += len(start)
-	e := strings.Index(str[s:], end)
-	if e == -1 {
-		return ""
-	}
-	return str[s : s+e]
+
+	return fmt.Sprint(output)
+}
+
+// LogStats writes the cache hit and miss counts to the debug log
+func (e *Evaluator) LogStats() {
+	e.mu.RLock()
+	size := len(e.templates)
+	dynamic := len(e.dynamic)
+	regexes := len(e.regexes)
+	e.mu.RUnlock()
+
+	slog.Debug("Template cache",
+		slog.Uint64("generation", e.generation),
+		slog.Int("size", size),
+		slog.Int("dynamic", dynamic),
+		slog.Int("regexes", regexes),
+		slog.Uint64("hits", e.hits.Load()),
+		slog.Uint64("misses", e.misses.Load()),
+	)
 }
@@ -0,0 +1,326 @@
+package evaluator
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/expr-lang/expr"
+	"github.com/jysk-network/netbox-securecrt-inventory/internal/netbox"
+)
+
+// regexFunc returns the compiled regular expression of a pattern, the evaluator caches them
+type regexFunc func(pattern string) (*regexp.Regexp, error)
+
+// getFunctions returns the helper functions available in all expressions
+func getFunctions(getRegex regexFunc) []expr.Option {
+	return []expr.Option{
+		expr.Function("HasTag", func(params ...any) (any, error) {
+			return hasTag(params[0], params[1].(string)), nil
+		}, new(func(any, string) bool)),
+		expr.Function("TagValue", func(params ...any) (any, error) {
+			return tagValue(params[0], params[1].(string)), nil
+		}, new(func(any, string) any)),
+		expr.Function("TagsWithPrefix", func(params ...any) (any, error) {
+			return tagsWithPrefix(params[0], params[1].(string)), nil
+		}, new(func(any, string) []string)),
+		expr.Function("Tags", func(params ...any) (any, error) {
+			return tagSlugs(params[0]), nil
+		}, new(func(any) []string)),
+		expr.Function("CustomField", func(params ...any) (any, error) {
+			return customField(params[0], params[1].(string), params[2]), nil
+		}, new(func(any, string, any) any)),
+		expr.Function("RegexMatch", func(params ...any) (any, error) {
+			re, err := getRegex(params[1].(string))
+			if err != nil {
+				return nil, err
+			}
+			return re.MatchString(params[0].(string)), nil
+		}, new(func(string, string) bool)),
+		expr.Function("RegexReplace", func(params ...any) (any, error) {
+			re, err := getRegex(params[1].(string))
+			if err != nil {
+				return nil, err
+			}
+			return re.ReplaceAllString(params[0].(string), params[2].(string)), nil
+		}, new(func(string, string, string) string)),
+		expr.Function("RegexCapture", func(params ...any) (any, error) {
+			re, err := getRegex(params[1].(string))
+			if err != nil {
+				return nil, err
+			}
+			return regexCapture(re, params...), nil
+		}, new(func(string, string) any), new(func(string, string, int) any)),
+		expr.Function("InSubnet", func(params ...any) (any, error) {
+			return inSubnet(params[0].(string), params[1].(string))
+		}, new(func(string, string) bool)),
+		expr.Function("IsIPv4", func(params ...any) (any, error) {
+			ip := parseIP(params[0].(string))
+			return ip != nil && ip.To4() != nil, nil
+		}, new(func(string) bool)),
+		expr.Function("IsIPv6", func(params ...any) (any, error) {
+			ip := parseIP(params[0].(string))
+			return ip != nil && ip.To4() == nil, nil
+		}, new(func(string) bool)),
+		expr.Function("Slugify", func(params ...any) (any, error) {
+			return slugify(params[0].(string)), nil
+		}, new(func(string) string)),
+		expr.Function("TitleCase", func(params ...any) (any, error) {
+			return titleCase(params[0].(string)), nil
+		}, new(func(string) string)),
+		expr.Function("ToInt", func(params ...any) (any, error) {
+			return toInt(params[0], params[1].(int)), nil
+		}, new(func(any, int) int)),
+		expr.Function("ToFloat", func(params ...any) (any, error) {
+			return toFloat(params[0], params[1].(float64)), nil
+		}, new(func(any, float64) float64)),
+		expr.Function("ToBool", func(params ...any) (any, error) {
+			return toBool(params[0], params[1].(bool)), nil
+		}, new(func(any, bool) bool)),
+		expr.Function("ToString", func(params ...any) (any, error) {
+			return toString(params[0]), nil
+		}, new(func(any) string)),
+	}
+}
+
+// getOptions returns the expr options used to compile expressions
+func getOptions(getRegex regexFunc) []expr.Option {
+	return append([]expr.Option{expr.Env(Environment{})}, getFunctions(getRegex)...)
+}
+
+// ConfigContext returns the value at the dotted path in the config context of the device, or def when
+// it's not set, list items are selected by index, ex: ConfigContext('console.ports.0', nil)
+func (e Environment) ConfigContext(path string, def any) any {
+	value := getMember(reflect.ValueOf(e.Device), "ConfigContext")
+	for _, part := range strings.Split(path, ".") {
+		value = indirect(value)
+		switch value.Kind() {
+		case reflect.Map:
+			value = value.MapIndex(reflect.ValueOf(part))
+		case reflect.Slice:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= value.Len() {
+				return def
+			}
+			value = value.Index(index)
+		default:
+			return def
+		}
+	}
+
+	value = indirect(value)
+	if !value.IsValid() {
+		return def
+	}
+	return value.Interface()
+}
+
+// getTags returns the name and slug of each tag, tags can be a list of NetBox tags,
+// a list of strings, or an object with tags, ex: device or site
+func getTags(tags any) [][2]string {
+	var result [][2]string
+	switch t := tags.(type) {
+	case []netbox.NestedTag:
+		for _, tag := range t {
+			result = append(result, [2]string{tag.Name, tag.Slug})
+		}
+	case []string:
+		for _, tag := range t {
+			result = append(result, [2]string{tag, tag})
+		}
+	case []any:
+		for _, tag := range t {
+			if s, ok := tag.(string); ok {
+				result = append(result, [2]string{s, s})
+			}
+		}
+	default:
+		value := getMember(reflect.ValueOf(tags), "Tags")
+		if value.IsValid() && value.CanInterface() {
+			return getTags(value.Interface())
+		}
+	}
+	return result
+}
+
+// hasTag returns true when a tag name or slug is exactly the given name
+func hasTag(tags any, name string) bool {
+	for _, tag := range getTags(tags) {
+		if tag[0] == name || tag[1] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// tagValue returns the value of the first "key:value" tag with the exact key, or nil
+func tagValue(tags any, key string) any {
+	values := tagsWithPrefix(tags, key)
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// tagsWithPrefix returns the values of all "key:value" tags with the exact key
+func tagsWithPrefix(tags any, key string) []string {
+	values := []string{}
+	for _, tag := range getTags(tags) {
+		if value, ok := strings.CutPrefix(tag[0], key+":"); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func tagSlugs(tags any) []string {
+	slugs := []string{}
+	for _, tag := range getTags(tags) {
+		slugs = append(slugs, tag[1])
+	}
+	return slugs
+}
+
+// customField returns the custom field of an object or custom fields map, or def when it's not set
+func customField(obj any, name string, def any) any {
+	fields, ok := obj.(map[string]any)
+	if !ok {
+		value := getMember(reflect.ValueOf(obj), "CustomFields")
+		if value.IsValid() && value.CanInterface() {
+			fields, _ = value.Interface().(map[string]any)
+		}
+	}
+
+	value, ok := fields[name]
+	if !ok || value == nil {
+		return def
+	}
+	return value
+}
+
+// regexCapture returns the capture group of the first match (the first group by default), or nil
+func regexCapture(re *regexp.Regexp, params ...any) any {
+	group := 1
+	if len(params) > 2 {
+		group = params[2].(int)
+	}
+
+	match := re.FindStringSubmatch(params[0].(string))
+	if match == nil || group < 0 || group >= len(match) {
+		return nil
+	}
+	return match[group]
+}
+
+// parseIP parses an ip address, with or without a prefix length as NetBox returns it, ex: 10.0.0.1/24
+func parseIP(value string) net.IP {
+	value, _, _ = strings.Cut(value, "/")
+	return net.ParseIP(strings.TrimSpace(value))
+}
+
+func inSubnet(ip string, cidr string) (bool, error) {
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false, err
+	}
+
+	address := parseIP(ip)
+	return address != nil && subnet.Contains(address), nil
+}
+
+var slugRe = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// slugify converts a value to a NetBox style slug, ex: "DK01 Core" returns "dk01-core"
+func slugify(value string) string {
+	return strings.Trim(slugRe.ReplaceAllString(strings.ToLower(value), "-"), "-")
+}
+
+// titleCase upper cases the first letter of each word, the rest of the word is kept as is
+func titleCase(value string) string {
+	runes := []rune(value)
+	for i := range runes {
+		if i == 0 || unicode.IsSpace(runes[i-1]) || runes[i-1] == '-' || runes[i-1] == '_' {
+			runes[i] = unicode.ToUpper(runes[i])
+		}
+	}
+	return string(runes)
+}
+
+func toInt(value any, def int) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case string:
+		i, err := strconv.Atoi(strings.TrimSpace(v))
+		if err == nil {
+			return i
+		}
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+	return def
+}
+
+func toFloat(value any, def float64) float64 {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err == nil {
+			return f
+		}
+	}
+	return def
+}
+
+func toBool(value any, def bool) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case int:
+		return v != 0
+	case float64:
+		return v != 0
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err == nil {
+			return b
+		}
+	}
+	return def
+}
+
+func toString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case *string:
+		if v == nil {
+			return ""
+		}
+		return *v
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,169 @@
+package evaluator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jysk-network/netbox-securecrt-inventory/internal/netbox"
+)
+
+func getTestEnvironment() *Environment {
+	return &Environment{
+		DeviceName: "sw01.example.com",
+		DeviceIP:   "10.0.1.5/24",
+		Tags:       []string{"core", "connection_protocol:Telnet"},
+		Device: &netbox.DeviceWithConfigContext{
+			Name: "sw01.example.com",
+			Tags: []netbox.NestedTag{
+				{Name: "Core", Slug: "core"},
+				{Name: "connection_protocol:Telnet", Slug: "connection_protocol-telnet"},
+				{Name: "path:Stores/DK", Slug: "path-stores-dk"},
+				{Name: "path:Stores/SE", Slug: "path-stores-se"},
+				{Name: "legacy-core", Slug: "legacy-core"},
+			},
+			CustomFields: map[string]interface{}{
+				"port_suffix": "22",
+				"rack_unit":   float64(12),
+				"empty":       nil,
+			},
+			ConfigContext: map[string]interface{}{
+				"console": map[string]interface{}{
+					"ports": []interface{}{"Port 1", "Port 2"},
+					"speed": float64(9600),
+				},
+			},
+		},
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want any
+	}{
+		// tags
+		{"HasTag by name", "HasTag(device, 'Core')", true},
+		{"HasTag by slug", "HasTag(device, 'core')", true},
+		{"HasTag is exact", "HasTag(device, 'cor')", false},
+		{"HasTag on a tag list", "HasTag(tags, 'core')", true},
+		{"HasTag on nested tags", "HasTag(device.Tags, 'legacy-core')", true},
+		{"TagValue", "TagValue(device, 'connection_protocol')", "Telnet"},
+		{"TagValue first match", "TagValue(device, 'path')", "Stores/DK"},
+		{"TagValue missing", "TagValue(device, 'firewall')", nil},
+		{"TagValue with default", "TagValue(device, 'firewall') ?? 'None'", "None"},
+		{"TagsWithPrefix", "TagsWithPrefix(device, 'path')", []string{"Stores/DK", "Stores/SE"}},
+		{"TagsWithPrefix missing", "TagsWithPrefix(device, 'missing')", []string{}},
+		{"Tags", "Tags(device)", []string{"core", "connection_protocol-telnet", "path-stores-dk", "path-stores-se", "legacy-core"}},
+		{"FindTag is exact", "FindTag(device.Tags, 'core')", nil},
+		{"FindTag value", "FindTag(device.Tags, 'connection_protocol')", "Telnet"},
+
+		// custom fields
+		{"CustomField", "CustomField(device, 'port_suffix', '')", "22"},
+		{"CustomField number", "CustomField(device, 'rack_unit', 0)", float64(12)},
+		{"CustomField nil uses default", "CustomField(device, 'empty', 'none')", "none"},
+		{"CustomField missing uses default", "CustomField(device, 'missing', 'none')", "none"},
+		{"CustomField on a map", "CustomField(device.CustomFields, 'port_suffix', '')", "22"},
+
+		// config context
+		{"ConfigContext dotted path", "ConfigContext('console.speed', 0)", float64(9600)},
+		{"ConfigContext list index", "ConfigContext('console.ports.1', nil)", "Port 2"},
+		{"ConfigContext index out of range", "ConfigContext('console.ports.5', 'none')", "none"},
+		{"ConfigContext missing key", "ConfigContext('console.missing', 'none')", "none"},
+		{"ConfigContext through a value", "ConfigContext('console.speed.value', 'none')", "none"},
+
+		// regex
+		{"RegexMatch", "RegexMatch(device_name, '^sw[0-9]+')", true},
+		{"RegexMatch no match", "RegexMatch(device_name, '^rt')", false},
+		{"RegexReplace", "RegexReplace(device_name, '\\\\.example\\\\.com$', '')", "sw01"},
+		{"RegexReplace groups", "RegexReplace(device_name, '^([a-z]+)([0-9]+).*', '$2-$1')", "01-sw"},
+		{"RegexCapture", "RegexCapture(device_name, '^([a-z]+)([0-9]+)')", "sw"},
+		{"RegexCapture group", "RegexCapture(device_name, '^([a-z]+)([0-9]+)', 2)", "01"},
+		{"RegexCapture no match", "RegexCapture(device_name, '^rt([0-9]+)')", nil},
+
+		// ip addresses
+		{"InSubnet", "InSubnet(device_ip, '10.0.0.0/16')", true},
+		{"InSubnet outside", "InSubnet(device_ip, '10.1.0.0/16')", false},
+		{"InSubnet ipv6", "InSubnet('2001:db8::1/64', '2001:db8::/32')", true},
+		{"IsIPv4", "IsIPv4(device_ip)", true},
+		{"IsIPv4 with ipv6", "IsIPv4('2001:db8::1')", false},
+		{"IsIPv4 invalid", "IsIPv4('not an ip')", false},
+		{"IsIPv6", "IsIPv6('2001:db8::1/64')", true},
+		{"IsIPv6 with ipv4", "IsIPv6(device_ip)", false},
+
+		// text
+		{"Slugify", "Slugify('DK01 Core/Access')", "dk01-core-access"},
+		{"Slugify trims", "Slugify(' --Store 12-- ')", "store-12"},
+		{"TitleCase", "TitleCase('access switch')", "Access Switch"},
+		{"TitleCase separators", "TitleCase('core-router_a')", "Core-Router_A"},
+		{"TitleCase keeps case", "TitleCase('iOS XR')", "IOS XR"},
+
+		// conversions
+		{"ToInt string", "ToInt(' 22 ', 0)", 22},
+		{"ToInt float", "ToInt(12.7, 0)", 12},
+		{"ToInt bool", "ToInt(true, 0)", 1},
+		{"ToInt invalid", "ToInt('abc', 7)", 7},
+		{"ToInt nil", "ToInt(nil, 7)", 7},
+		{"ToFloat string", "ToFloat('1.5', 0.0)", 1.5},
+		{"ToFloat int", "ToFloat(2, 0.0)", float64(2)},
+		{"ToFloat invalid", "ToFloat('abc', 0.5)", 0.5},
+		{"ToBool string", "ToBool('true', false)", true},
+		{"ToBool number", "ToBool(0, true)", false},
+		{"ToBool invalid", "ToBool('maybe', true)", true},
+		{"ToString number", "ToString(22)", "22"},
+		{"ToString nil", "ToString(nil)", ""},
+		{"ToString bool", "ToString(false)", "false"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			template, err := Compile("{{ " + test.expr + " }}")
+			if err != nil {
+				t.Fatalf("failed to compile %s: %s", test.expr, err)
+			}
+
+			got, err := template.Evaluate(getTestEnvironment())
+			if err != nil {
+				t.Fatalf("failed to evaluate %s: %s", test.expr, err)
+			}
+
+			// FindTag returns a string pointer
+			if s, ok := got.(*string); ok {
+				got = nil
+				if s != nil {
+					got = *s
+				}
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("%s = %#v, want %#v", test.expr, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFunctionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"RegexMatch invalid pattern", "RegexMatch(device_name, '(')"},
+		{"RegexReplace invalid pattern", "RegexReplace(device_name, '[', '')"},
+		{"RegexCapture invalid pattern", "RegexCapture(device_name, '(')"},
+		{"InSubnet invalid subnet", "InSubnet(device_ip, '10.0.0.0')"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			template, err := Compile("{{ " + test.expr + " }}")
+			if err != nil {
+				t.Fatalf("failed to compile %s: %s", test.expr, err)
+			}
+
+			_, err = template.Evaluate(getTestEnvironment())
+			if err == nil {
+				t.Errorf("%s should return an error", test.expr)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package evaluator
+
+// LookupFunc returns the value of key in a lookup table, or def when the table or key doesn't exist,
+// it's called as lookup(table, key, default) from expressions
+type LookupFunc func(table string, key string, def any) any
+
+// Lookup returns the value of key in one of the lookup tables of the evaluator, or def
+func (e *Evaluator) Lookup(table string, key string, def any) any {
+	value, ok := e.lookups[table][key]
+	if !ok {
+		return def
+	}
+	return value
+}
@@ -0,0 +1,125 @@
+package evaluator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ResultError is returned when a template can't be evaluated, or its result can't be used for the field
+type ResultError struct {
+	Device   string
+	Field    string
+	Template string
+	Err      error
+}
+
+func (e *ResultError) Error() string {
+	return fmt.Sprintf("%s: %s: '%s': %s", e.Device, e.Field, e.Template, e.Err)
+}
+
+func (e *ResultError) Unwrap() error {
+	return e.Err
+}
+
+// EvaluateString evaluates a template for a string field, ok is false when the result is nil
+// and the current value of the field should be kept
+func (e *Evaluator) EvaluateString(field string, template string, env *Environment) (value string, ok bool, err error) {
+	output, err := e.EvaluateResult(template, env)
+	if err == nil {
+		value, ok, err = AsString(output)
+	}
+	if err != nil {
+		return "", false, &ResultError{Device: env.DeviceName, Field: field, Template: template, Err: err}
+	}
+	return value, ok, nil
+}
+
+// EvaluateInt evaluates a template for a number field, ok is false when the result is nil
+// and the current value of the field should be kept
+func (e *Evaluator) EvaluateInt(field string, template string, env *Environment) (value int, ok bool, err error) {
+	output, err := e.EvaluateResult(template, env)
+	if err == nil {
+		value, ok, err = AsInt(output)
+	}
+	if err != nil {
+		return 0, false, &ResultError{Device: env.DeviceName, Field: field, Template: template, Err: err}
+	}
+	return value, ok, nil
+}
+
+// AsString converts a result to a string, numbers and bools are formatted, ok is false for nil
+func AsString(value any) (string, bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", false, nil
+	case string:
+		return v, true, nil
+	case *string:
+		if v == nil {
+			return "", false, nil
+		}
+		return *v, true, nil
+	case int:
+		return strconv.Itoa(v), true, nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), true, nil
+	case int64:
+		return strconv.FormatInt(v, 10), true, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true, nil
+	case bool:
+		return strconv.FormatBool(v), true, nil
+	}
+	return "", false, fmt.Errorf("should return a string, got %T", value)
+}
+
+// AsInt converts a result to a number, strings are parsed and floats need to be whole numbers, ok is false for nil
+func AsInt(value any) (int, bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, false, nil
+	case int:
+		return v, true, nil
+	case int32:
+		return int(v), true, nil
+	case int64:
+		return int(v), true, nil
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), true, nil
+		}
+	case string, *string:
+		s, ok, _ := AsString(v)
+		if !ok {
+			return 0, false, nil
+		}
+		i, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return 0, false, fmt.Errorf("should return a number, got '%s'", s)
+		}
+		return i, true, nil
+	}
+	return 0, false, fmt.Errorf("should return a number, got %v (%T)", value, value)
+}
+
+// AsBool converts a result to a bool, strings are parsed, ok is false for nil
+func AsBool(value any) (bool, bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return false, false, nil
+	case bool:
+		return v, true, nil
+	case string, *string:
+		s, ok, _ := AsString(v)
+		if !ok {
+			return false, false, nil
+		}
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return false, false, fmt.Errorf("should return true or false, got '%s'", s)
+		}
+		return b, true, nil
+	}
+	return false, false, fmt.Errorf("should return true or false, got %v (%T)", value, value)
+}
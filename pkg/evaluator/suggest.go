@@ -0,0 +1,104 @@
+package evaluator
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Suggest returns the environment fields starting with the last word of the input, dotted
+// paths are looked up in the fields of NetBox objects and the keys of maps,
+// ex: "device.Pla" returns "device.Platform"
+func Suggest(env *Environment, input string) []string {
+	word := input[strings.LastIndexFunc(input, func(r rune) bool { return !isCompletionChar(r) })+1:]
+	parts := strings.Split(word, ".")
+	prefix := parts[len(parts)-1]
+
+	var names []string
+	if len(parts) == 1 {
+		for _, field := range Fields(env) {
+			names = append(names, field.Name)
+		}
+	} else {
+		value, ok := lookupField(env, parts[0])
+		if !ok {
+			return nil
+		}
+
+		for _, part := range parts[1 : len(parts)-1] {
+			value = getMember(value, part)
+		}
+		names = getMemberNames(value)
+	}
+
+	base := strings.TrimSuffix(word, prefix)
+	var result []string
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			result = append(result, base+name)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func lookupField(env *Environment, name string) (reflect.Value, bool) {
+	i, ok := fieldIndex[name]
+	if !ok {
+		return reflect.Value{}, false
+	}
+	return reflect.ValueOf(env).Elem().Field(i), true
+}
+
+func isCompletionChar(r rune) bool {
+	return r == '_' || r == '.' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// indirect follows interfaces and pointers, returning an invalid value for nil
+func indirect(value reflect.Value) reflect.Value {
+	for value.IsValid() && (value.Kind() == reflect.Interface || value.Kind() == reflect.Pointer) {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+	return value
+}
+
+func getMember(value reflect.Value, name string) reflect.Value {
+	value = indirect(value)
+	switch value.Kind() {
+	case reflect.Struct:
+		return value.FieldByName(name)
+	case reflect.Map:
+		if value.Type().Key().Kind() == reflect.String {
+			return value.MapIndex(reflect.ValueOf(name))
+		}
+	}
+	return reflect.Value{}
+}
+
+func getMemberNames(value reflect.Value) []string {
+	value = indirect(value)
+	var names []string
+	switch value.Kind() {
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if field.IsExported() && !field.Anonymous {
+				names = append(names, field.Name)
+			}
+			// fields of embedded structs are promoted
+			if field.Anonymous {
+				names = append(names, getMemberNames(value.Field(i))...)
+			}
+		}
+	case reflect.Map:
+		if value.Type().Key().Kind() == reflect.String {
+			for _, key := range value.MapKeys() {
+				names = append(names, key.String())
+			}
+		}
+	}
+	return names
+}
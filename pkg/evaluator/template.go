@@ -0,0 +1,267 @@
+package evaluator
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// Template is a compiled template, a mix of literal text, {variable} placeholders
+// and {{ expression }} blocks. Literal braces are escaped with a backslash, ex: \{
+type Template struct {
+	source string
+	parts  []templatePart
+}
+
+type templatePart struct {
+	text       string
+	variable   string
+	expression string
+	program    *vm.Program
+}
+
+// fieldIndex maps the expr tags of the environment to the field index, used to resolve variables
+var fieldIndex = getFieldIndex()
+
+func getFieldIndex() map[string]int {
+	index := make(map[string]int)
+	t := reflect.TypeOf(Environment{})
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("expr"); tag != "" {
+			index[tag] = i
+		}
+	}
+
+	// user defined variables are "vars" in expressions, and {var.name} in templates
+	index["var"] = index["vars"]
+	return index
+}
+
+// Compile tokenizes and compiles a template, returning an error if any expression is invalid,
+// the regular expressions are not cached as the evaluator's are
+func Compile(source string) (*Template, error) {
+	return compile(source, regexp.Compile)
+}
+
+func compile(source string, getRegex regexFunc) (*Template, error) {
+	t := &Template{source: source}
+	var text strings.Builder
+	addText := func() {
+		if text.Len() > 0 {
+			t.parts = append(t.parts, templatePart{text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(source); i++ {
+		// escaped braces are literal text
+		if source[i] == '\\' && i+1 < len(source) && (source[i+1] == '{' || source[i+1] == '}') {
+			text.WriteByte(source[i+1])
+			i++
+			continue
+		}
+
+		if strings.HasPrefix(source[i:], "{{") {
+			end := strings.Index(source[i+2:], "}}")
+			if end == -1 {
+				return nil, fmt.Errorf("expression is missing closing }} in '%s'", source)
+			}
+
+			expression := strings.TrimSpace(source[i+2 : i+2+end])
+			program, err := expr.Compile(expression, getOptions(getRegex)...)
+			if err != nil {
+				return nil, err
+			}
+
+			addText()
+			t.parts = append(t.parts, templatePart{expression: expression, program: program})
+			i += end + 3
+			continue
+		}
+
+		if source[i] == '{' {
+			end := strings.IndexAny(source[i+1:], "{}")
+			if end != -1 && source[i+1+end] == '}' && isVariableName(source[i+1:i+1+end]) {
+				addText()
+				t.parts = append(t.parts, templatePart{variable: source[i+1 : i+1+end]})
+				i += end + 1
+				continue
+			}
+		}
+
+		text.WriteByte(source[i])
+	}
+
+	addText()
+	return t, nil
+}
+
+func isVariableName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, c := range name {
+		if !(c == '_' || c == '.' || c == ':' || c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsExpression returns true if the template is a single expression, its result keeps its type
+func (t *Template) IsExpression() bool {
+	return len(t.parts) == 1 && t.parts[0].program != nil
+}
+
+// Kind returns the result type of the template, templates that are not a single expression
+// always return a string, and reflect.Interface is returned when the type is only known at runtime
+func (t *Template) Kind() reflect.Kind {
+	if !t.IsExpression() {
+		return reflect.String
+	}
+
+	resultType := t.parts[0].program.Node().Type()
+	if resultType == nil {
+		return reflect.Interface
+	}
+	return resultType.Kind()
+}
+
+// Evaluate returns the typed result for a single expression, and a string otherwise
+func (t *Template) Evaluate(env *Environment) (any, error) {
+	if t.IsExpression() {
+		return expr.Run(t.parts[0].program, env)
+	}
+
+	var result strings.Builder
+	for _, part := range t.parts {
+		switch {
+		case part.program != nil:
+			output, err := expr.Run(part.program, env)
+			if err != nil {
+				return nil, err
+			}
+
+			if output != nil {
+				result.WriteString(fmt.Sprint(output))
+			}
+		case part.variable != "":
+			result.WriteString(resolveVariable(part.variable, env))
+		default:
+			result.WriteString(part.text)
+		}
+	}
+
+	return result.String(), nil
+}
+
+// Variables returns the names of the {variable} placeholders in the template
+func (t *Template) Variables() []string {
+	var names []string
+	for _, part := range t.parts {
+		if part.variable != "" {
+			names = append(names, part.variable)
+		}
+	}
+	return names
+}
+
+// IsVariable returns true when resolveVariable can resolve the placeholder name, unknown placeholders
+// are kept as text, ex: a typo in a field name, or a key on a field that is not a list or map
+func IsVariable(name string) bool {
+	if table, keyVariable, ok := strings.Cut(strings.TrimPrefix(name, "lookup:"), ":"); ok && strings.HasPrefix(name, "lookup:") {
+		return table != "" && IsVariable(keyVariable)
+	}
+
+	field, key, hasKey := strings.Cut(name, ".")
+	i, ok := fieldIndex[field]
+	if !ok {
+		return false
+	}
+
+	fieldType := reflect.TypeOf(Environment{}).Field(i).Type
+	switch fieldType.Kind() {
+	case reflect.String, reflect.Int, reflect.Bool:
+		return !hasKey
+	case reflect.Slice:
+		if !hasKey {
+			return fieldType.Elem().Kind() == reflect.String
+		}
+		_, err := strconv.Atoi(key)
+		return fieldType.Elem().Kind() == reflect.String && err == nil
+	case reflect.Map:
+		return hasKey && key != ""
+	}
+	return false
+}
+
+// resolveVariable returns the value of a variable, lists are joined by "," or accessed by index,
+// ex: {region_names.0}, maps are accessed by key, ex: {custom_fields.owner}, and lookup tables
+// by the value of a variable, ex: {lookup:folders:site_group}.
+// Unknown variables are kept as they are.
+func resolveVariable(name string, env *Environment) string {
+	// lookups use the value of another variable as key, ex: {lookup:platform_credentials:device_platform_slug}
+	if table, keyVariable, ok := strings.Cut(strings.TrimPrefix(name, "lookup:"), ":"); ok && strings.HasPrefix(name, "lookup:") {
+		if env.Lookup == nil {
+			return ""
+		}
+
+		value := env.Lookup(table, resolveVariable(keyVariable, env), nil)
+		if value == nil {
+			return ""
+		}
+		return fmt.Sprint(value)
+	}
+
+	v := reflect.ValueOf(env).Elem()
+	field, key, hasKey := strings.Cut(name, ".")
+	i, ok := fieldIndex[field]
+	if !ok {
+		return "{" + name + "}"
+	}
+
+	value := v.Field(i)
+	switch value.Kind() {
+	case reflect.String:
+		if !hasKey {
+			return value.String()
+		}
+	case reflect.Int:
+		if !hasKey {
+			return strconv.Itoa(int(value.Int()))
+		}
+	case reflect.Bool:
+		if !hasKey {
+			return strconv.FormatBool(value.Bool())
+		}
+	case reflect.Slice:
+		values, ok := value.Interface().([]string)
+		if ok && !hasKey {
+			return strings.Join(values, ",")
+		}
+
+		index, err := strconv.Atoi(key)
+		if ok && err == nil {
+			if index >= 0 && index < len(values) {
+				return values[index]
+			}
+			return ""
+		}
+	case reflect.Map:
+		values, ok := value.Interface().(map[string]interface{})
+		if ok && hasKey {
+			if values[key] == nil {
+				return ""
+			}
+			return fmt.Sprint(values[key])
+		}
+	}
+
+	return "{" + name + "}"
+}
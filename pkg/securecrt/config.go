@@ -15,7 +15,7 @@ func getConfigPath() (string, error) {
 		}
 		return fmt.Sprintf("%s/.vandyke/SecureCRT/Config", homeDir), nil
 	}
-	
+
 	appDataDir, err := os.UserConfigDir()
 	if err != nil {
 		return "", ErrFailedToExpandHomeDir
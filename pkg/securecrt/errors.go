@@ -8,4 +8,9 @@ var (
 	ErrFailedToLoadCredentials = errors.New("failed to load default credentials")
 	ErrFailedToCreateSession   = errors.New("failed to create session")
 	ErrFailedToReadSession     = errors.New("failed to read session")
+	ErrInvalidSerialSettings   = errors.New("invalid serial settings")
+	ErrInvalidSessionKey       = errors.New("invalid session key")
+	ErrNoNetBoxObject          = errors.New("session has no netbox object")
+	ErrSessionExists           = errors.New("session already exists")
+	ErrUnknownProtocol         = errors.New("unknown protocol, should be one of SSH2, SSH1, Telnet, RLogin, Raw, Serial or TAPI")
 )
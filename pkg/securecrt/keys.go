@@ -0,0 +1,62 @@
+package securecrt
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SecureCRTSessionKey is a session setting without a field in SecureCRTSession,
+// ex: D:"Idle NO-OP Check"=00000001
+type SecureCRTSessionKey struct {
+	Type  string
+	Name  string
+	Value string
+}
+
+// sessionKeyRe matches session keys in the ini format, the quotes are optional, ex: S:"Emulation" or D:Idle Timeout
+var sessionKeyRe = regexp.MustCompile(`^([SDZ]):"?([^"=]+?)"?$`)
+
+// ParseSessionKey returns the type and name of a session key, ok is false when it's not a session key
+func ParseSessionKey(key string) (itemType string, name string, ok bool) {
+	match := sessionKeyRe.FindStringSubmatch(strings.TrimSpace(key))
+	if match == nil {
+		return "", "", false
+	}
+	return match[1], match[2], true
+}
+
+// NewSessionKey returns a session key, the value of D keys should be a number
+func NewSessionKey(itemType string, name string, value string) (*SecureCRTSessionKey, error) {
+	if IsSessionField(name) {
+		return nil, fmt.Errorf("%w: '%s' is set by the session", ErrInvalidSessionKey, name)
+	}
+
+	if itemType == "D" {
+		if _, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: '%s' should be a number, got '%s'", ErrInvalidSessionKey, name, value)
+		}
+	}
+
+	return &SecureCRTSessionKey{Type: itemType, Name: name, Value: value}, nil
+}
+
+// IsSessionField returns true for keys written from the fields of the session, they can't be set as extra keys
+func IsSessionField(name string) bool {
+	for _, t := range []reflect.Type{reflect.TypeOf(SecureCRTSession{}), reflect.TypeOf(SecureCRTSerialSettings{})} {
+		for i := 0; i < t.NumField(); i++ {
+			if strings.EqualFold(t.Field(i).Tag.Get("session"), name) {
+				return true
+			}
+		}
+	}
+
+	for _, portKey := range Protocols {
+		if portKey != "" && strings.EqualFold(portKey, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package securecrt
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SecureCRTNetBoxObject is the NetBox object a session is generated from, it's written as a marker
+// line in the description, ex: netbox-object: dcim.device 42 default https://netbox/dcim/devices/42/
+// Session tells the sessions of the same object apart, ex: the variant or console port
+type SecureCRTNetBoxObject struct {
+	Type    string
+	Id      int
+	Session string
+	Url     string
+	// Keys are the names of the extra session keys written by the sync, so they are reset when they're no longer written
+	Keys []string
+}
+
+const (
+	NETBOX_MARKER_PREFIX      = "netbox-object:"
+	NETBOX_MARKER_KEYS_PREFIX = "keys="
+)
+
+var netboxMarkerRe = regexp.MustCompile(`^` + NETBOX_MARKER_PREFIX + ` *([a-z_]+\.[a-z_]+) +([0-9]+)((?: +\S+)*)$`)
+
+func (o *SecureCRTNetBoxObject) marker() string {
+	marker := fmt.Sprintf("%s %s %d", NETBOX_MARKER_PREFIX, o.Type, o.Id)
+	if o.Session != "" {
+		marker += " " + url.PathEscape(o.Session)
+	}
+	if o.Url != "" {
+		marker += " " + o.Url
+	}
+	if len(o.Keys) > 0 {
+		keys := make([]string, 0, len(o.Keys))
+		for _, key := range o.Keys {
+			keys = append(keys, url.PathEscape(key))
+		}
+		marker += " " + NETBOX_MARKER_KEYS_PREFIX + strings.Join(keys, ",")
+	}
+	return marker
+}
+
+// Identity returns the key of the session, it stays the same when the session is renamed or moved
+func (o *SecureCRTNetBoxObject) Identity() string {
+	return fmt.Sprintf("%s/%d/%s", o.Type, o.Id, o.Session)
+}
+
+// ParseNetBoxMarker returns the NetBox object of the marker line in a session description, ok is false without a marker
+func ParseNetBoxMarker(description string) (object *SecureCRTNetBoxObject, ok bool) {
+	for _, line := range strings.Split(description, "\n") {
+		match := netboxMarkerRe.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+
+		id, err := strconv.Atoi(match[2])
+		if err != nil {
+			continue
+		}
+		object = &SecureCRTNetBoxObject{Type: match[1], Id: id}
+		for _, field := range strings.Fields(match[3]) {
+			if strings.Contains(field, "://") {
+				object.Url = field
+				continue
+			}
+
+			if keys, ok := strings.CutPrefix(field, NETBOX_MARKER_KEYS_PREFIX); ok {
+				for _, key := range strings.Split(keys, ",") {
+					name, err := url.PathUnescape(key)
+					if err == nil && name != "" {
+						object.Keys = append(object.Keys, name)
+					}
+				}
+				continue
+			}
+
+			object.Session, err = url.PathUnescape(field)
+			if err != nil {
+				object.Session = field
+			}
+		}
+		return object, true
+	}
+	return nil, false
+}
+
+// withNetBoxMarker returns the description with the marker line of the object, replacing an existing marker
+func withNetBoxMarker(description string, object *SecureCRTNetBoxObject) string {
+	var lines []string
+	for _, line := range strings.Split(description, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), NETBOX_MARKER_PREFIX) {
+			lines = append(lines, line)
+		}
+	}
+
+	description = strings.TrimRight(strings.Join(lines, "\n"), "\n")
+	if object == nil {
+		return description
+	}
+
+	if description == "" {
+		return object.marker()
+	}
+	return description + "\n" + object.marker()
+}
+
+// GetNetBoxObject returns the NetBox object of a session file, the file can be a full path, or the
+// session path relative to the SecureCRT sessions folder as shown in SecureCRT, ex: NetBox/Stores/sw01
+func (scrt *SecureCRT) GetNetBoxObject(file string) (*SecureCRTNetBoxObject, error) {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(scrt.configPath, "Sessions", file)
+	}
+
+	if !strings.HasSuffix(file, ".ini") {
+		file += ".ini"
+	}
+
+	if _, err := os.Stat(file); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToReadSession, err)
+	}
+
+	session := NewSession(file)
+	err := session.read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToReadSession, err)
+	}
+
+	if session.NetBox == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoNetBoxObject, file)
+	}
+	return session.NetBox, nil
+}
@@ -0,0 +1,40 @@
+package securecrt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Protocols are the protocol names supported by SecureCRT, with the key used for the port,
+// protocols without a port key ignore the session port
+var Protocols = map[string]string{
+	"SSH2":   "[SSH2] Port",
+	"SSH1":   "[SSH1] Port",
+	"Telnet": "[Telnet] Port",
+	"RLogin": "[RLogin] Port",
+	"Raw":    "[Raw] Port",
+	"Serial": "",
+	"TAPI":   "",
+}
+
+// protocolAliases are accepted names that are not SecureCRT protocol names
+var protocolAliases = map[string]string{
+	"SSH": "SSH2",
+}
+
+// NormalizeProtocol returns the SecureCRT protocol name, matched without case
+func NormalizeProtocol(protocol string) (string, error) {
+	for alias, name := range protocolAliases {
+		if strings.EqualFold(protocol, alias) {
+			return name, nil
+		}
+	}
+
+	for name := range Protocols {
+		if strings.EqualFold(protocol, name) {
+			return name, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: '%s'", ErrUnknownProtocol, protocol)
+}
@@ -80,6 +80,50 @@ func (scrt *SecureCRT) GetSessions() ([]*SecureCRTSession, error) {
 	return sessions, err
 }
 
+// GetSessionsByIdentity returns the existing sessions generated from a NetBox object, by their identity
+func (scrt *SecureCRT) GetSessionsByIdentity() (map[string]*SecureCRTSession, error) {
+	sessions := make(map[string]*SecureCRTSession)
+	if _, err := os.Stat(scrt.sessionPath); errors.Is(err, fs.ErrNotExist) {
+		return sessions, nil
+	}
+
+	currentSessions, err := scrt.GetSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range currentSessions {
+		if session.NetBox == nil {
+			continue
+		}
+
+		identity := session.NetBox.Identity()
+		if existing, ok := sessions[identity]; ok {
+			slog.Warn("multiple sessions for the same netbox object", slog.String("session", session.fullPath), slog.String("existing", existing.fullPath))
+			continue
+		}
+		sessions[identity] = session
+	}
+
+	return sessions, nil
+}
+
+// MoveSession renames the file of an existing session to fullPath, so it's kept when the name
+// or path of the object changes, instead of being deleted and created again
+func (scrt *SecureCRT) MoveSession(existing *SecureCRTSession, fullPath string) error {
+	info, err := os.Stat(scrt.configPath)
+	if err != nil {
+		return err
+	}
+
+	return existing.move(fullPath, info.Mode())
+}
+
+// DeleteSession removes the file of a session, and the folders left empty
+func (scrt *SecureCRT) DeleteSession(session *SecureCRTSession) error {
+	return session.delete()
+}
+
 func (scrt *SecureCRT) RemoveSessions(sessions []*SecureCRTSession) error {
 	currentSessions, err := scrt.GetSessions()
 	if err != nil {
@@ -0,0 +1,80 @@
+package securecrt
+
+import "fmt"
+
+var serialParity = map[string]int{
+	"none":  0,
+	"odd":   1,
+	"even":  2,
+	"mark":  3,
+	"space": 4,
+}
+
+var serialStopBits = map[string]int{
+	"1":   0,
+	"1.5": 1,
+	"2":   2,
+}
+
+var serialFlowControl = []string{"none", "xon", "rts/cts", "dtr/dsr"}
+
+// SecureCRTSerialSettings holds the Serial protocol keys, they are only written when
+// the session has serial settings
+type SecureCRTSerialSettings struct {
+	ComPort        string `session:"Com Port" type:"S"`
+	BaudRate       int    `session:"Baud Rate" type:"D"`
+	DataBits       int    `session:"Data Bits" type:"D"`
+	Parity         int    `session:"Parity" type:"D"`
+	StopBits       int    `session:"Stop Bits" type:"D"`
+	CtsFlow        int    `session:"CTS Flow" type:"D"`
+	RtsFlowControl int    `session:"RTS Flow Control" type:"D"`
+	DsrFlow        int    `session:"DSR Flow" type:"D"`
+	DtrFlowControl int    `session:"DTR Flow Control" type:"D"`
+	XonFlow        int    `session:"XON Flow" type:"D"`
+}
+
+// NewSerialSettings returns settings for 8 data bits, no parity, 1 stop bit and no flow control
+func NewSerialSettings(comPort string, baudRate int) *SecureCRTSerialSettings {
+	return &SecureCRTSerialSettings{
+		ComPort:  comPort,
+		BaudRate: baudRate,
+		DataBits: 8,
+	}
+}
+
+func (s *SecureCRTSerialSettings) SetParity(parity string) error {
+	value, ok := serialParity[parity]
+	if !ok {
+		return fmt.Errorf("%w: unknown parity '%s'", ErrInvalidSerialSettings, parity)
+	}
+
+	s.Parity = value
+	return nil
+}
+
+func (s *SecureCRTSerialSettings) SetStopBits(stopBits string) error {
+	value, ok := serialStopBits[stopBits]
+	if !ok {
+		return fmt.Errorf("%w: unknown stop bits '%s'", ErrInvalidSerialSettings, stopBits)
+	}
+
+	s.StopBits = value
+	return nil
+}
+
+func (s *SecureCRTSerialSettings) SetFlowControl(flowControl string) error {
+	s.CtsFlow, s.RtsFlowControl, s.DsrFlow, s.DtrFlowControl, s.XonFlow = 0, 0, 0, 0, 0
+	switch flowControl {
+	case "none":
+	case "xon":
+		s.XonFlow = 1
+	case "rts/cts":
+		s.CtsFlow, s.RtsFlowControl = 1, 1
+	case "dtr/dsr":
+		s.DsrFlow, s.DtrFlowControl = 1, 1
+	default:
+		return fmt.Errorf("%w: unknown flow control '%s', should be one of %v", ErrInvalidSerialSettings, flowControl, serialFlowControl)
+	}
+
+	return nil
+}
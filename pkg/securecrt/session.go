@@ -10,6 +10,8 @@ import (
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -17,11 +19,15 @@ type SecureCRTSession struct {
 	DeviceName     string
 	Path           string
 	IP             string `session:"Hostname" type:"S"`
-	Port           int    `session:"[SSH2] Port" type:"D"`
-	Protocol       string `session:"Protocol Name" type:"S"`
-	Description    string `session:"Description" type:"Z"`
-	CredentialName string `session:"Credential Title" type:"S"`
-	Firewall       string `session:"Firewall Name" type:"S"`
+	Port           int
+	Protocol       string  `session:"Protocol Name" type:"S"`
+	Description    string  `session:"Description" type:"Z"`
+	CredentialName string  `session:"Credential Title" type:"S"`
+	Username       *string `session:"Username" type:"S"`
+	Firewall       string  `session:"Firewall Name" type:"S"`
+	Serial         *SecureCRTSerialSettings
+	Keys           []SecureCRTSessionKey
+	NetBox         *SecureCRTNetBoxObject
 	fullPath       string
 }
 
@@ -76,6 +82,10 @@ func (s *SecureCRTSession) read() error {
 		return err
 	}
 
+	if object, ok := ParseNetBoxMarker(s.Description); ok {
+		s.NetBox = object
+	}
+
 	// set DeviceName and Path manually as they are file names
 	configPath, _ := getConfigPath()
 	configPath = configPath + "/Sessions"
@@ -103,47 +113,101 @@ func (s *SecureCRTSession) setInternalValue(key string, value string) {
 	}
 }
 
-func (s *SecureCRTSession) write(defaultConfig string, mode fs.FileMode) error {
+// GetFullPath returns the path of the session file
+func (s *SecureCRTSession) GetFullPath() string {
+	return s.fullPath
+}
+
+// SetDeviceName renames the session, the file name is changed with it
+func (s *SecureCRTSession) SetDeviceName(name string) {
+	s.DeviceName = name
+	s.fullPath = filepath.Join(filepath.Dir(s.fullPath), name+".ini")
+}
+
+// Preview returns the session keys that would be written, without the default config
+func (s *SecureCRTSession) Preview() (string, error) {
+	return s.encode("")
+}
+
+func (s *SecureCRTSession) encode(defaultConfig string) (string, error) {
 	var data strings.Builder
 	data.WriteString(defaultConfig)
 
+	protocol, err := NormalizeProtocol(s.Protocol)
+	if err != nil {
+		return "", err
+	}
+	s.Protocol = protocol
+
+	// the netbox object is added to the description, so it's kept when the session is edited in SecureCRT
+	session := *s
+	if s.NetBox != nil {
+		object := *s.NetBox
+		object.Keys = nil
+		for _, key := range s.Keys {
+			object.Keys = append(object.Keys, key.Name)
+		}
+		session.Description = withNetBoxMarker(s.Description, &object)
+	}
+
 	// based on the tags we can generate the correct securecrt config format
-	val := reflect.ValueOf(s).Elem()
-	for i := 0; i < val.NumField(); i++ {
-		itemType := val.Type().Field(i).Tag.Get("type")
-		key := val.Type().Field(i).Tag.Get("session")
-		if key == "" {
+	writeFields(&data, reflect.ValueOf(&session).Elem())
+
+	// the port key depends on the protocol, ex: [SSH2] Port or [Telnet] Port
+	if portKey := Protocols[s.Protocol]; portKey != "" && s.Port != 0 {
+		data.WriteString(fmt.Sprintf("D:\"%s\"=%08X\n", portKey, s.Port))
+	}
+	if s.Serial != nil {
+		writeFields(&data, reflect.ValueOf(s.Serial).Elem())
+	}
+
+	for _, key := range s.Keys {
+		if key.Type == "D" {
+			value, err := strconv.ParseInt(strings.TrimSpace(key.Value), 10, 64)
+			if err != nil {
+				return "", fmt.Errorf("%w: '%s' should be a number", ErrInvalidSessionKey, key.Name)
+			}
+			writeKey(&data, key.Type, key.Name, "", value)
 			continue
 		}
+		writeKey(&data, key.Type, key.Name, key.Value, 0)
+	}
 
-		value := val.Field(i).String()
-		if val.Field(i).Kind() == reflect.Pointer {
-			value = val.Field(i).Elem().String()
-		}
+	return data.String(), nil
+}
 
-		if itemType == "Z" {
-			items := strings.Split(value, "\n")
-			itemsLengthPadded := fmt.Sprintf("%08d", len(items))
-			data.WriteString(fmt.Sprintf("%s:\"%s\"=%s\n", itemType, key, itemsLengthPadded))
-			for _, v := range items {
-				if v != "" {
-					data.WriteString(" " + v + "\n")
-				}
+func (s *SecureCRTSession) write(defaultConfig string, mode fs.FileMode) error {
+	data, err := s.encode(defaultConfig)
+	if err != nil {
+		return err
+	}
+
+	// an existing session of the same object keeps the keys changed in SecureCRT, only the keys set by the
+	// sync are replaced, the file of another object is replaced so its settings are not carried over
+	existing, err := os.ReadFile(s.fullPath)
+	if err == nil {
+		previous, ok := ParseNetBoxMarker(string(existing))
+		if !ok || s.NetBox == nil || previous.Identity() == s.NetBox.Identity() {
+			managed, err := s.encode("")
+			if err != nil {
+				return err
 			}
-		} else if itemType == "D" {
-			data.WriteString(fmt.Sprintf("%s:\"%s\"=%08X\n", itemType, key, val.Field(i).Int()))
-		} else {
-			data.WriteString(fmt.Sprintf("%s:\"%s\"=%s\n", itemType, key, value))
+
+			var previousKeys []string
+			if ok {
+				previousKeys = previous.Keys
+			}
+			data = mergeKeys(string(existing), managed, defaultConfig, previousKeys)
 		}
 	}
 
-	err := os.MkdirAll(filepath.Dir(s.fullPath), mode)
+	err = os.MkdirAll(filepath.Dir(s.fullPath), mode)
 	if err != nil {
 		slog.Error("failed to create securecrt session directory", slog.String("error", err.Error()))
 		return errors.Join(ErrFailedToCreateSession, err)
 	}
 
-	err = os.WriteFile(s.fullPath, []byte(data.String()), mode)
+	err = os.WriteFile(s.fullPath, []byte(data), mode)
 	if err != nil {
 		slog.Error("failed to write securecrt session", slog.String("error", err.Error()))
 		return errors.Join(ErrFailedToCreateSession, err)
@@ -152,14 +216,170 @@ func (s *SecureCRTSession) write(defaultConfig string, mode fs.FileMode) error {
 	return nil
 }
 
+var sessionKeyLineRe = regexp.MustCompile(`^[A-Z]:"(.*)"=`)
+
+// sessionKeyLines are the lines of a key in a session file, multiline keys continue on the lines starting with a space
+type sessionKeyLines struct {
+	name  string
+	lines []string
+}
+
+// splitKeys returns the keys of a session file in file order, lines before the first key have no name
+func splitKeys(data string) []*sessionKeyLines {
+	var keys []*sessionKeyLines
+	if data == "" {
+		return keys
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(data, "\r\n"), "\n") {
+		line = strings.TrimRight(line, "\r")
+		match := sessionKeyLineRe.FindStringSubmatch(line)
+		if match != nil || len(keys) == 0 {
+			key := &sessionKeyLines{}
+			if match != nil {
+				key.name = match[1]
+			}
+			keys = append(keys, key)
+		}
+
+		keys[len(keys)-1].lines = append(keys[len(keys)-1].lines, line)
+	}
+	return keys
+}
+
+// mergeKeys replaces the keys of data with the keys of update, and appends the keys of update that are not in data.
+// The keys set by the sync that are not in update, the session fields and the previous extra keys, are reset to
+// their value in defaults, or removed, ex: the username when it's no longer set
+func mergeKeys(data string, update string, defaults string, previous []string) string {
+	defaultKeys := make(map[string]*sessionKeyLines)
+	for _, key := range splitKeys(defaults) {
+		if _, ok := defaultKeys[key.name]; !ok && key.name != "" {
+			defaultKeys[key.name] = key
+		}
+	}
+
+	updates := make(map[string]*sessionKeyLines)
+	var added []*sessionKeyLines
+	for _, key := range splitKeys(update) {
+		if _, ok := updates[key.name]; !ok {
+			updates[key.name] = key
+			added = append(added, key)
+		}
+	}
+
+	var merged strings.Builder
+	written := make(map[string]bool)
+	for _, key := range splitKeys(data) {
+		if update, ok := updates[key.name]; ok && key.name != "" {
+			// a key written twice is only replaced once
+			if written[key.name] {
+				continue
+			}
+			key = update
+			written[key.name] = true
+		} else if key.name != "" && (IsSessionField(key.name) || slices.Contains(previous, key.name)) {
+			defaultKey, ok := defaultKeys[key.name]
+			if !ok || written[key.name] {
+				continue
+			}
+			key = defaultKey
+			written[key.name] = true
+		}
+
+		for _, line := range key.lines {
+			merged.WriteString(line + "\n")
+		}
+	}
+
+	for _, key := range added {
+		if key.name == "" || written[key.name] {
+			continue
+		}
+
+		for _, line := range key.lines {
+			merged.WriteString(line + "\n")
+		}
+	}
+
+	return merged.String()
+}
+
+func writeFields(data *strings.Builder, val reflect.Value) {
+	for i := 0; i < val.NumField(); i++ {
+		itemType := val.Type().Field(i).Tag.Get("type")
+		key := val.Type().Field(i).Tag.Get("session")
+		if key == "" {
+			continue
+		}
+
+		value := val.Field(i).String()
+		if val.Field(i).Kind() == reflect.Pointer {
+			// optional values are only written when set, so the default config is used otherwise
+			if val.Field(i).IsNil() {
+				continue
+			}
+			value = val.Field(i).Elem().String()
+		}
+
+		var number int64
+		if itemType == "D" {
+			number = val.Field(i).Int()
+		}
+		writeKey(data, itemType, key, value, number)
+	}
+}
+
+// writeKey writes a key in the securecrt config format, number is used for D keys and value for the others
+func writeKey(data *strings.Builder, itemType string, key string, value string, number int64) {
+	if itemType == "Z" {
+		items := strings.Split(value, "\n")
+		itemsLengthPadded := fmt.Sprintf("%08d", len(items))
+		data.WriteString(fmt.Sprintf("%s:\"%s\"=%s\n", itemType, key, itemsLengthPadded))
+		for _, v := range items {
+			if v != "" {
+				data.WriteString(" " + v + "\n")
+			}
+		}
+	} else if itemType == "D" {
+		data.WriteString(fmt.Sprintf("%s:\"%s\"=%08X\n", itemType, key, number))
+	} else {
+		data.WriteString(fmt.Sprintf("%s:\"%s\"=%s\n", itemType, key, value))
+	}
+}
+
 func (s *SecureCRTSession) delete() error {
 	err := os.Remove(s.fullPath)
 	if err != nil {
 		return err
 	}
 
+	return removeEmptyFolders(filepath.Dir(s.fullPath))
+}
+
+// move renames the session file to fullPath, and removes the folders left empty
+func (s *SecureCRTSession) move(fullPath string, mode fs.FileMode) error {
+	if _, err := os.Stat(fullPath); err == nil {
+		return fmt.Errorf("%w: %s", ErrSessionExists, fullPath)
+	}
+
+	err := os.MkdirAll(filepath.Dir(fullPath), mode)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(s.fullPath, fullPath)
+	if err != nil {
+		return err
+	}
+
+	oldPath := s.fullPath
+	s.fullPath = fullPath
+	return removeEmptyFolders(filepath.Dir(oldPath))
+}
+
+func removeEmptyFolders(dir string) error {
 	// find the highest-level folder that is “empty” (ignoring this child folder and some ignored files)
-	folder, err := getFolderToDelete(filepath.Dir(s.fullPath))
+	folder, err := getFolderToDelete(dir)
 	if err != nil {
 		return err
 	}